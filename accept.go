@@ -0,0 +1,27 @@
+package main
+
+import "flag"
+
+var acceptUnique = flag.Bool("accept-unique", false, "select the only match as soon as the query narrows the results to one, once indexing is done")
+
+// uniqueMatch is signalled by Recalculate when -accept-unique should select.
+var uniqueMatch = make(chan struct{}, 1)
+
+// checkUnique signals uniqueMatch if m leaves exactly one match that
+// can't change: a result that's unique while the walk or background scoring
+// is still running might not be moments later. b.mu must be held.
+func (b *resultsBox) checkUnique(m matcher) {
+	if !*acceptUnique || !b.walkDone || b.scoring != nil || b.shortQuery {
+		return
+	}
+	if len(m.value) == 0 || len(b.matches) != 1 || b.isHeader(0) {
+		return
+	}
+	// a query that matched nothing leaves no selection, and SelectBestMatch
+	// may not have run by the time the signal is acted on
+	b.selected = 0
+	select {
+	case uniqueMatch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAcceptUnique(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	defer func(on bool) { *acceptUnique = on }(*acceptUnique)
+	defer setQuery("")
+	*acceptUnique = true
+	drain := func() {
+		select {
+		case <-uniqueMatch:
+		default:
+		}
+	}
+	signalled := func() bool {
+		select {
+		case <-uniqueMatch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	api := filepath.FromSlash("/base/api")
+	b := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	b.setIndex([]string{api, filepath.FromSlash("/base/docs"), filepath.FromSlash("/base/src")})
+	for _, tt := range []struct {
+		query    string
+		walkDone bool
+		want     bool
+	}{
+		// more paths may yet match
+		{query: "api", walkDone: false},
+		{query: "s", walkDone: true},
+		{query: "", walkDone: true},
+		{query: "nothing", walkDone: true},
+		{query: "api", walkDone: true, want: true},
+	} {
+		b.mu.Lock()
+		b.walkDone = tt.walkDone
+		b.mu.Unlock()
+		setQuery(tt.query)
+		// only this Recalculate may signal
+		drain()
+		b.Recalculate()
+		if got := signalled(); got != tt.want {
+			t.Errorf("%+v: selected is %v", tt, got)
+		}
+		if !tt.want {
+			continue
+		}
+		if got, ok := b.Selection(); !ok || got != api {
+			t.Errorf("%+v: the selection is %q, want the lone match %s", tt, got, api)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// recalcBudget is how long Recalculate scores before showing what it has and
+// finishing the rest in the background, so typing stays fluid on huge indexes.
+const recalcBudget = 30 * time.Millisecond
+
+// scoreUntil appends the paths that match m to matches, in order, giving up
+// early once stop returns true. It reports how many paths it scored. bases
+// holds the base name of each path, or is nil to find them as needed.
+func scoreUntil(m matcher, matches, paths, bases []string, stop func() bool) ([]string, int) {
+	// everything matches an empty query
+	if len(m.value) == 0 {
+		return append(matches, paths...), len(paths)
+	}
+	for i, path := range paths {
+		// checking the clock is cheap, but not next to scoring one path
+		if i%256 == 0 && i > 0 && stop() {
+			return matches, i
+		}
+		base := ""
+		if bases != nil {
+			base = bases[i]
+		} else if m.base {
+			base = filepath.Base(path)
+		}
+		if m.scoreIndexed(path, base) > 0 {
+			matches = append(matches, path)
+		}
+	}
+	return matches, len(paths)
+}
+
+// finishMatching scores rest against m in the background and caches it, after
+// partial, as the matches for key, recalculating if key is still current by
+// then. Work for a query or index that has since changed is abandoned. b.mu
+// must be held.
+func (b *resultsBox) finishMatching(m matcher, key matchKey, partial, rest, bases []string) {
+	if b.scoring != nil && *b.scoring == key {
+		return
+	}
+	b.scoring = &key
+	// AppendFilepaths replaces filepaths rather than changing it, so rest can
+	// be shared, but partial is about to be appended to
+	partial = append([]string(nil), partial...)
+	go func() {
+		matches, n := scoreUntil(m, partial, rest, bases, func() bool {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			return b.cacheKey() != key
+		})
+
+		b.mu.Lock()
+		if b.scoring != nil && *b.scoring == key {
+			b.scoring = nil
+		}
+		current := n == len(rest) && b.cacheKey() == key
+		if current {
+			b.cache.Put(key, matches)
+		}
+		b.mu.Unlock()
+
+		if current {
+			b.Recalculate()
+			b.SelectBestMatch()
+			draw()
+		}
+	}()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestRecalculateBudget checks that a keystroke on an index too big to score
+// within recalcBudget still returns promptly, and that the rest of the
+// matches follow.
+func TestRecalculateBudget(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	b := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	b.setIndex(benchIndex(300000))
+	want := matching(b.filepaths, testMatcher("cfg", false))
+
+	setQuery("cfg")
+	defer setQuery("")
+	start := time.Now()
+	b.Recalculate()
+	if took := time.Since(start); took > 10*recalcBudget {
+		t.Errorf("Recalculate took %v with a budget of %v", took, recalcBudget)
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		b.mu.Lock()
+		matches := b.matches
+		b.mu.Unlock()
+		if reflect.DeepEqual(matches, want) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d matches, want all %d", len(matches), len(want))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// BenchmarkKeystroke measures how long the picker is unresponsive for each
+// keystroke on a large index, with nothing cached.
+func BenchmarkKeystroke(b *testing.B) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	index := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	index.setIndex(benchIndex(1000000))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		setQuery("c")
+		index.mu.Lock()
+		index.generation++
+		index.mu.Unlock()
+		index.Recalculate()
+	}
+	setQuery("")
+	b.StopTimer()
+	// let the abandoned background scoring notice before the basepath goes
+	for {
+		index.mu.Lock()
+		scoring := index.scoring != nil
+		index.mu.Unlock()
+		if !scoring {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A config file sets flags, one per line, as name = value in a small subset
+// of TOML: strings in double or single quotes, true and false, bare numbers,
+// and arrays of strings for repeatable flags like exclude. Names are the flag
+// names without the dash. Blank lines and lines starting with # are skipped.
+//
+// Settings apply in order of precedence from low to high: the defaults, the
+// global config, the basepath's .nav.toml, and then the command line. A flag
+// given on the command line ignores both files; arrays in both files add up.
+//
+// A .nav.toml comes with whatever repository was checked out, so it may only
+// set the projectSettings, which change what is indexed and how it matches,
+// and never anything that runs a command or decides where output goes.
+
+// projectSettings are the flags a basepath's .nav.toml may set.
+var projectSettings = map[string]bool{
+	"exclude":            true,
+	"ignore-mode":        true,
+	"no-default-ignores": true,
+	"symlinks":           true,
+	"one-filesystem":     true,
+	"max-per-dir":        true,
+	"max-index":          true,
+	"no-self":            true,
+	"sort":               true,
+	"recent":             true,
+	"scorer":             true,
+	"component-weight":   true,
+	"boundary-bonus":     true,
+	"ext-weight":         true,
+	"match-basename":     true,
+	"ignore-accents":     true,
+	"min-query":          true,
+	"nth":                true,
+	"with-nth":           true,
+}
+
+// globalConfigPath is where the config shared by every basepath lives.
+func globalConfigPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "nav", "config.toml"), nil
+}
+
+// loadConfigs applies the global config and then basepath's .nav.toml to
+// every flag not given on the command line. A missing file is fine; a
+// malformed one is reported on stderr and ignored as a whole.
+func loadConfigs(basepath string) {
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	if path, err := globalConfigPath(); err == nil {
+		loadConfig(path, given, nil)
+	}
+	loadConfig(filepath.Join(basepath, ".nav.toml"), given, projectSettings)
+}
+
+// loadConfig applies the settings in path that aren't given, skipping any not
+// in allowed unless it is nil.
+func loadConfig(path string, given, allowed map[string]bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			warnf("reading %s: %v", path, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	settings, err := parseConfig(f)
+	if err != nil {
+		warnf("%s: %v, ignoring the file", path, err)
+		return
+	}
+	for _, s := range settings {
+		if given[s.name] {
+			continue
+		}
+		if allowed != nil && !allowed[s.name] {
+			warnf("%s:%d: %s can't be set for a project, ignoring it", path, s.line, s.name)
+			continue
+		}
+		for _, value := range s.values {
+			if err := flag.Set(s.name, value); err != nil {
+				warnf("%s:%d: %s: %v", path, s.line, s.name, err)
+			}
+		}
+	}
+}
+
+// warnf reports a problem found while starting up. It runs before the screen
+// takes over the terminal, so it goes to stderr, where it's seen without DEBUG.
+func warnf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "nav: "+format+"\n", args...)
+}
+
+// setting is one name = value line, with an array's elements as its values.
+type setting struct {
+	name   string
+	values []string
+	line   int
+}
+
+// parseConfig reads every setting, failing on the first line it can't parse
+// or that names no flag.
+func parseConfig(r io.Reader) ([]setting, error) {
+	var settings []setting
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: want name = value", n)
+		}
+		name := strings.TrimSpace(line[:eq])
+		if flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("line %d: unknown setting %q", n, name)
+		}
+		values, err := parseConfigValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		settings = append(settings, setting{name: name, values: values, line: n})
+	}
+	return settings, scanner.Err()
+}
+
+// parseConfigValue returns the flag values a TOML value stands for: one for a
+// scalar, or one per element of an array of strings.
+func parseConfigValue(s string) ([]string, error) {
+	if strings.HasPrefix(s, "[") {
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("unterminated array %s", s)
+		}
+		var values []string
+		for _, elem := range strings.Split(s[1:len(s)-1], ",") {
+			elem = strings.TrimSpace(elem)
+			if elem == "" {
+				continue
+			}
+			value, err := parseConfigString(elem)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		value, err := parseConfigString(s)
+		return []string{value}, err
+	}
+	// true, false and numbers are passed on as written
+	if s == "" || strings.ContainsAny(s, " \t#") {
+		return nil, fmt.Errorf("bad value %q", s)
+	}
+	return []string{s}, nil
+}
+
+// parseConfigString unquotes a basic "string" or a literal 'string'.
+func parseConfigString(s string) (string, error) {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return s[1 : len(s)-1], nil
+	}
+	value, err := strconv.Unquote(s)
+	if err != nil || !strings.HasPrefix(s, `"`) {
+		return "", fmt.Errorf("bad string %s", s)
+	}
+	return value, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	settings, err := parseConfig(strings.NewReader(`
+# comment
+sort = "shortest"
+min-query = 2
+symlinks = true
+exclude = ["fixtures", 'testdata']
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []setting{
+		{name: "sort", values: []string{"shortest"}, line: 3},
+		{name: "min-query", values: []string{"2"}, line: 4},
+		{name: "symlinks", values: []string{"true"}, line: 5},
+		{name: "exclude", values: []string{"fixtures", "testdata"}, line: 6},
+	}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("got %+v, want %+v", settings, want)
+	}
+
+	for _, bad := range []string{
+		"sort",
+		`nonsense = "x"`,
+		`exclude = ["a"`,
+		`sort = "unterminated`,
+		"sort = two words",
+	} {
+		if _, err := parseConfig(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q parsed without error", bad)
+		}
+	}
+}
+
+// writeConfig writes content to a file named name in a new directory.
+func writeConfig(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	defer func(sort, scorer string, min int) {
+		*sortMode, *scorerName, *minQuery = sort, scorer, min
+	}(*sortMode, *scorerName, *minQuery)
+
+	global := writeConfig(t, "config.toml", "sort = \"shortest\"\nscorer = \"components\"\nmin-query = 3\n")
+	defer os.RemoveAll(filepath.Dir(global))
+	project := writeConfig(t, ".nav.toml", "sort = \"score\"\nmin-query = 4\n")
+	defer os.RemoveAll(filepath.Dir(project))
+
+	// as if -min-query was on the command line
+	given := map[string]bool{"min-query": true}
+	*minQuery = 1
+	loadConfig(global, given, nil)
+	loadConfig(project, given, projectSettings)
+
+	if *sortMode != "score" {
+		t.Errorf("-sort is %q, want the project's score over the global shortest", *sortMode)
+	}
+	if *scorerName != "components" {
+		t.Errorf("-scorer is %q, want the global components", *scorerName)
+	}
+	if *minQuery != 1 {
+		t.Errorf("-min-query is %d, want the command line's 1", *minQuery)
+	}
+}
+
+func TestProjectConfigAllowlist(t *testing.T) {
+	defer func(exec, sort string) {
+		*execCmd, *sortMode = exec, sort
+	}(*execCmd, *sortMode)
+
+	project := writeConfig(t, ".nav.toml", "exec = \"rm -rf {}\"\nsort = \"shortest\"\n")
+	defer os.RemoveAll(filepath.Dir(project))
+	*execCmd = ""
+	captureStderr(t, func() { loadConfig(project, map[string]bool{}, projectSettings) })
+	if *execCmd != "" {
+		t.Errorf("a project config set -exec to %q", *execCmd)
+	}
+	if *sortMode != "shortest" {
+		t.Errorf("-sort is %q, want the project's shortest alongside the ignored -exec", *sortMode)
+	}
+
+	global := writeConfig(t, "config.toml", "exec = \"less {}\"\n")
+	defer os.RemoveAll(filepath.Dir(global))
+	loadConfig(global, map[string]bool{}, nil)
+	if *execCmd != "less {}" {
+		t.Errorf("-exec is %q, want the global config's less {}", *execCmd)
+	}
+}
+
+// captureStderr returns what f prints to stderr.
+func captureStderr(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer func(stderr *os.File) { os.Stderr = stderr }(os.Stderr)
+	os.Stderr = w
+	f()
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	return string(out)
+}
+
+func TestConfigWarnings(t *testing.T) {
+	defer func(exec string) { *execCmd = exec }(*execCmd)
+
+	project := writeConfig(t, ".nav.toml", "exec = \"rm -rf {}\"\n")
+	defer os.RemoveAll(filepath.Dir(project))
+	out := captureStderr(t, func() {
+		loadConfig(project, map[string]bool{}, projectSettings)
+	})
+	if want := "nav: " + project + ":1: exec can't be set for a project, ignoring it\n"; out != want {
+		t.Errorf("printed %q, want %q", out, want)
+	}
+
+	broken := writeConfig(t, ".nav.toml", "exec\n")
+	defer os.RemoveAll(filepath.Dir(broken))
+	out = captureStderr(t, func() {
+		loadConfig(broken, map[string]bool{}, nil)
+	})
+	if !strings.HasPrefix(out, "nav: "+broken+": ") || !strings.HasSuffix(out, ", ignoring the file\n") {
+		t.Errorf("printed %q, want a warning that %s is ignored", out, broken)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var contractVars = flag.String("contract", "HOME", "comma-separated environment variables whose values are shown as ~ (for HOME) or $NAME at the start of absolute paths (empty for none)")
+
+// contraction replaces a directory with a shorter name for display.
+type contraction struct {
+	dir, name string
+}
+
+var (
+	contractions     []contraction
+	contractionsOnce sync.Once
+)
+
+// loadContractions reads -contract's variables, longest value first so that
+// $GOPATH wins over ~ for paths under both. Unset and relative values are
+// skipped.
+func loadContractions() {
+	for _, v := range strings.Split(*contractVars, ",") {
+		v = strings.TrimSpace(v)
+		dir := os.Getenv(v)
+		if v == "" || !filepath.IsAbs(dir) {
+			continue
+		}
+		name := "$" + v
+		if v == "HOME" {
+			name = "~"
+		}
+		contractions = append(contractions, contraction{dir: filepath.Clean(dir), name: name})
+	}
+	sort.SliceStable(contractions, func(i, j int) bool {
+		return len(contractions[i].dir) > len(contractions[j].dir)
+	})
+}
+
+// contractPath shortens the start of path for display. Selections are always
+// printed in full.
+func contractPath(path string) string {
+	contractionsOnce.Do(loadContractions)
+	for _, c := range contractions {
+		if path == c.dir {
+			return c.name
+		}
+		if strings.HasPrefix(path, c.dir+string(filepath.Separator)) {
+			return c.name + path[len(c.dir):]
+		}
+	}
+	return path
+}
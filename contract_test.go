@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestContractPath(t *testing.T) {
+	reload := func() {
+		contractions, contractionsOnce = nil, sync.Once{}
+	}
+	defer reload()
+	defer func(vars, home, gopath string) {
+		*contractVars = vars
+		os.Setenv("HOME", home)
+		os.Setenv("GOPATH", gopath)
+	}(*contractVars, os.Getenv("HOME"), os.Getenv("GOPATH"))
+
+	home, gopath := filepath.FromSlash("/home/u"), filepath.FromSlash("/home/u/go")
+	os.Setenv("HOME", home)
+	os.Setenv("GOPATH", gopath)
+	os.Setenv("NAV_RELATIVE", "rel")
+	defer os.Unsetenv("NAV_RELATIVE")
+	*contractVars = "HOME, GOPATH,NAV_RELATIVE,NAV_UNSET"
+	reload()
+
+	for path, want := range map[string]string{
+		"/home/u":        "~",
+		"/home/u/src":    "~/src",
+		"/home/u/go/src": "$GOPATH/src",
+		"/home/user2":    "/home/user2",
+		"/tmp":           "/tmp",
+		"rel/src":        "rel/src",
+	} {
+		if got := contractPath(filepath.FromSlash(path)); got != filepath.FromSlash(want) {
+			t.Errorf("%s: got %s, want %s", path, got, want)
+		}
+	}
+
+	*contractVars = ""
+	reload()
+	if got := contractPath(filepath.FromSlash("/home/u/src")); got != filepath.FromSlash("/home/u/src") {
+		t.Errorf("empty -contract still shortened to %s", got)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+import "os"
+
+// deviceID is unsupported here, so -one-filesystem has no effect.
+func deviceID(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
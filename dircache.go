@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxCachedInfos bounds how many directory entries the cache holds in all.
+// Listings past it are read from disk every time.
+const maxCachedInfos = 1 << 20
+
+// dirCache remembers directory listings so that a refresh only re-reads the
+// directories whose modification time has changed. It holds nothing until
+// the first refresh: the initial walk reads each directory once, so caching
+// it would only hold on to memory for a refresh that may never come.
+type dirCache struct {
+	mu      sync.Mutex
+	enabled bool
+	entries map[string]dirCacheEntry
+	// size is the number of infos across entries
+	size int
+}
+
+type dirCacheEntry struct {
+	modTime time.Time
+	infos   []os.FileInfo
+}
+
+var listings = &dirCache{entries: map[string]dirCacheEntry{}}
+
+// Enable starts caching listings, for the refresh about to walk the tree.
+func (c *dirCache) Enable() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.enabled = true
+}
+
+// ReadDir is ioutil.ReadDir, served from the cache while dirname is unchanged.
+func (c *dirCache) ReadDir(dirname string) ([]os.FileInfo, error) {
+	c.mu.Lock()
+	enabled := c.enabled
+	c.mu.Unlock()
+	if !enabled {
+		return ioutil.ReadDir(dirname)
+	}
+
+	info, err := os.Stat(dirname)
+	if err != nil {
+		c.Invalidate(dirname)
+		return nil, err
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[dirname]
+	c.mu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) {
+		return entry.infos, nil
+	}
+
+	infos, err := ioutil.ReadDir(dirname)
+	if err != nil {
+		c.Invalidate(dirname)
+		return nil, err
+	}
+	c.mu.Lock()
+	c.remove(dirname)
+	if c.size+len(infos) <= maxCachedInfos {
+		c.entries[dirname] = dirCacheEntry{modTime: info.ModTime(), infos: infos}
+		c.size += len(infos)
+	}
+	c.mu.Unlock()
+	return infos, nil
+}
+
+func (c *dirCache) Invalidate(dirname string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(dirname)
+}
+
+// remove drops dirname's listing. c.mu must be held.
+func (c *dirCache) remove(dirname string) {
+	c.size -= len(c.entries[dirname].infos)
+	delete(c.entries, dirname)
+}
+
+// Clear forgets every listing, forcing the next walk to read from disk.
+func (c *dirCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[string]dirCacheEntry{}
+	c.size = 0
+}
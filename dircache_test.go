@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "a"), 0755)
+
+	c := &dirCache{entries: map[string]dirCacheEntry{}}
+	if _, err := c.ReadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.entries) != 0 {
+		t.Errorf("cached %d listings before any refresh", len(c.entries))
+	}
+
+	c.Enable()
+	infos, _ := c.ReadDir(dir)
+	if len(infos) != 1 || c.size != 1 {
+		t.Fatalf("got %d infos and a cache of %d, want 1 and 1", len(infos), c.size)
+	}
+
+	// a listing is read again once the directory changes
+	os.Mkdir(filepath.Join(dir, "b"), 0755)
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(dir, later, later)
+	if infos, _ := c.ReadDir(dir); len(infos) != 2 || c.size != 2 {
+		t.Errorf("got %d infos and a cache of %d, want 2 and 2", len(infos), c.size)
+	}
+
+	c.Invalidate(dir)
+	if len(c.entries) != 0 || c.size != 0 {
+		t.Errorf("%d listings and %d infos left after invalidating", len(c.entries), c.size)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+)
+
+var disambiguate = flag.Bool("disambiguate", false, "show results by base name, followed by just enough of the parent directories to tell apart visible results of the same name")
+
+// contextSep separates a base name from the parents that disambiguate it.
+const contextSep = " — "
+
+// parentContexts returns, for each of paths, the fewest trailing parent
+// directories that set it apart from the other paths with the same base name,
+// or "" if no other path has its base name. A path whose parents all trail
+// another's gets every one of them, and a path with no parents gets "./".
+func parentContexts(paths []string) []string {
+	parents := make([][]string, len(paths))
+	byBase := map[string][]int{}
+	for i, path := range paths {
+		if dir := filepath.Dir(path); dir != "." {
+			parents[i] = strings.Split(dir, string(filepath.Separator))
+		}
+		base := filepath.Base(path)
+		byBase[base] = append(byBase[base], i)
+	}
+
+	suffix := func(i, n int) string {
+		if n > len(parents[i]) {
+			n = len(parents[i])
+		}
+		return filepath.Join(parents[i][len(parents[i])-n:]...)
+	}
+	contexts := make([]string, len(paths))
+	for _, same := range byBase {
+		if len(same) < 2 {
+			continue
+		}
+		for _, i := range same {
+			n := 1
+			for ; n < len(parents[i]); n++ {
+				clash := false
+				for _, j := range same {
+					if j != i && suffix(j, n) == suffix(i, n) {
+						clash = true
+						break
+					}
+				}
+				if !clash {
+					break
+				}
+			}
+			contexts[i] = suffix(i, n)
+			if contexts[i] == "" {
+				contexts[i] = "." + string(filepath.Separator)
+			}
+		}
+	}
+	return contexts
+}
+
+// visibleContexts disambiguates the rows on screen from one another, keyed
+// by path. b.mu must be held.
+func (b *resultsBox) visibleContexts() map[string]string {
+	start, end := b.visibleRange()
+	paths := make([]string, 0, end-start)
+	for i := start; i < end; i++ {
+		paths = append(paths, search.relativePath(b.matches[i]))
+	}
+	contexts := map[string]string{}
+	for i, context := range parentContexts(paths) {
+		if context != "" {
+			contexts[b.matches[start+i]] = context
+		}
+	}
+	return contexts
+}
+
+// shortLabel is path as -disambiguate shows it, using the contexts of the
+// last draw. b.mu must be held.
+func (b *resultsBox) shortLabel(path string) string {
+	rel := search.relativePath(path)
+	if rel == "." {
+		return search.displayPath(path)
+	}
+	label := filepath.Base(rel)
+	if context := b.contexts[path]; context != "" {
+		label += contextSep + context
+	}
+	return label
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParentContexts(t *testing.T) {
+	paths := []string{
+		"src",
+		"api/src",
+		"web/api/src",
+		"docs",
+		"a/b/config",
+		"c/b/config",
+	}
+	for i := range paths {
+		paths[i] = filepath.FromSlash(paths[i])
+	}
+	want := []string{
+		"./",
+		"api",
+		"web/api",
+		"",
+		"a/b",
+		"c/b",
+	}
+	for i := range want {
+		want[i] = filepath.FromSlash(want[i])
+	}
+	if got := parentContexts(paths); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+var (
+	execCmd  = flag.String("exec", "", "shell command Ctrl-X runs on the highlighted path, with each {} replaced by the quoted path (appended if there is no {})")
+	execQuit = flag.Bool("exec-quit", false, "exit once the -exec command finishes instead of returning to the picker")
+)
+
+// errExecQuit ends run once the -exec command has run under -exec-quit. The
+// command had the path, so nothing is printed.
+var errExecQuit = errors.New("-exec command finished")
+
+// execCommand builds the -exec command line for path. The path is quoted
+// wherever it is substituted, so the command itself should not quote {}.
+func execCommand(path string) *exec.Cmd {
+	line := *execCmd
+	if strings.Contains(line, "{}") {
+		line = strings.Replace(line, "{}", posixQuote(path), -1)
+	} else {
+		line += " " + posixQuote(path)
+	}
+	return exec.Command("/bin/sh", "-c", line)
+}
+
+// execSelection runs -exec on path on the terminal, resuming the picker
+// afterwards. Only a failure to resume is returned.
+func execSelection(path string) error {
+	if *execCmd == "" {
+		search.Notify("no -exec command")
+		return nil
+	}
+	return suspend(execCommand(path), func(err error) {
+		log.Printf("-exec: %v", err)
+		search.Notify("exec failed")
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestExecQuitOutput runs -exec with -exec-quit from Ctrl-X and checks that
+// nav exits cleanly without printing anything after the command.
+func TestExecQuitOutput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs nav in a child process")
+	}
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// NAV_TEST_MAIN splits on spaces, so the command is one script
+	script := filepath.Join(dir, "mark")
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\n: >\"$1.ran\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ran := filepath.Join(dir, "src.ran")
+
+	master, slave := openPty(t)
+	defer master.Close()
+	defer slave.Close()
+	var stdout bytes.Buffer
+	cmd, output := runNav(t, master, slave, &stdout, dir, "-no-self -exec-quit -exec "+script)
+
+	// src, the only match without the basepath, is listed once the walk reaches it
+	for start := time.Now(); !bytes.Contains(output(), []byte("src")); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			cmd.Process.Kill()
+			t.Fatalf("the picker never listed src: %q", output())
+		}
+	}
+	master.Write([]byte{0x18}) // Ctrl-X
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("exited with %v, want success", err)
+		}
+	case <-time.After(5 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("nav didn't exit after the command")
+	}
+	if _, err := os.Stat(ran); err != nil {
+		t.Errorf("the -exec command didn't run: %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("printed %q after the command, want nothing", stdout.String())
+	}
+}
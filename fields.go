@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The fields of a candidate are counted from 1, or from -1 at the end, as
+// they are listed: relative to the basepath for a walk, and as read for -stdin
+// and -from. -delimiter separates them; by default, lines read in are split on
+// runs of whitespace, like awk does, and paths on the path separator.
+var (
+	matchFields   = &fieldsFlag{}
+	displayFields = &fieldsFlag{}
+	acceptFields  = &fieldsFlag{}
+
+	fieldDelim = flag.String("delimiter", "", "what separates the fields -nth, -with-nth and -accept-nth select (default whitespace for -stdin and -from, the path separator otherwise)")
+)
+
+func init() {
+	flag.Var(matchFields, "nth", "match only these fields, e.g. -1 for the last, 1,3 or 2.. (comma-separated N, -N, N.., ..N or N..M)")
+	flag.Var(displayFields, "with-nth", "show only these fields, in the same syntax as -nth")
+	flag.Var(acceptFields, "accept-nth", "print only these fields of the selection, in the same syntax as -nth")
+}
+
+// fieldRange is an inclusive range of fields. Negative indexes count from the
+// end, and 0 leaves that end open.
+type fieldRange struct {
+	from, to int
+}
+
+// fieldsFlag selects fields. It selects all of them when empty.
+type fieldsFlag struct {
+	spec   string
+	ranges []fieldRange
+}
+
+func (f *fieldsFlag) String() string {
+	return f.spec
+}
+
+func (f *fieldsFlag) Set(value string) error {
+	var ranges []fieldRange
+	for _, part := range strings.Split(value, ",") {
+		r, err := parseFieldRange(part)
+		if err != nil {
+			return err
+		}
+		ranges = append(ranges, r)
+	}
+	f.spec, f.ranges = value, ranges
+	return nil
+}
+
+func parseFieldRange(s string) (fieldRange, error) {
+	index := func(s string) (int, error) {
+		if s == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("%q is not a field index: want N or -N", s)
+		}
+		return n, nil
+	}
+	if i := strings.Index(s, ".."); i >= 0 {
+		from, err := index(s[:i])
+		if err != nil {
+			return fieldRange{}, err
+		}
+		to, err := index(s[i+2:])
+		return fieldRange{from, to}, err
+	}
+	if s == "" {
+		return fieldRange{}, fmt.Errorf("empty field")
+	}
+	n, err := index(s)
+	return fieldRange{n, n}, err
+}
+
+// apply keeps the selected fields of line, in order, rejoined by the
+// delimiter, or a single space for whitespace. Selecting fields that line
+// doesn't have leaves nothing.
+func (f *fieldsFlag) apply(line string) string {
+	if len(f.ranges) == 0 {
+		return line
+	}
+	fields, sep := splitFields(line)
+	var kept []string
+	for _, r := range f.ranges {
+		from, to := r.resolve(len(fields))
+		for i := from; i <= to; i++ {
+			kept = append(kept, fields[i])
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+// splitFields splits line into fields by -delimiter or its default, returning
+// the separator to rejoin them with.
+func splitFields(line string) ([]string, string) {
+	switch {
+	case *fieldDelim != "":
+		return strings.Split(line, *fieldDelim), *fieldDelim
+	case readingList():
+		return strings.Fields(line), " "
+	}
+	sep := string(filepath.Separator)
+	return strings.Split(line, sep), sep
+}
+
+// resolve turns r into 0-based indexes into n fields, clamped to them. The
+// range is empty when from > to.
+func (r fieldRange) resolve(n int) (from, to int) {
+	at := func(i, open int) int {
+		switch {
+		case i == 0:
+			return open
+		case i < 0:
+			return n + i
+		}
+		return i - 1
+	}
+	from, to = at(r.from, 0), at(r.to, n-1)
+	if from < 0 {
+		from = 0
+	}
+	if to > n-1 {
+		to = n - 1
+	}
+	return from, to
+}
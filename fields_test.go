@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+// testFields parses spec as -nth would.
+func testFields(t *testing.T, spec string) *fieldsFlag {
+	f := &fieldsFlag{}
+	if err := f.Set(spec); err != nil {
+		t.Fatalf("%q: %v", spec, err)
+	}
+	return f
+}
+
+func TestFieldsSet(t *testing.T) {
+	for _, bad := range []string{"", "0", "a", "1,", "1..x", "..0"} {
+		if err := (&fieldsFlag{}).Set(bad); err == nil {
+			t.Errorf("%q parsed without error", bad)
+		}
+	}
+}
+
+func TestFieldsApplyPaths(t *testing.T) {
+	for _, tt := range []struct {
+		spec, path, want string
+	}{
+		{"1", "src/nav/cmd", "src"},
+		{"-1", "src/nav/cmd", "cmd"},
+		{"2..", "src/nav/cmd", "nav/cmd"},
+		{"..2", "src/nav/cmd", "src/nav"},
+		{"1,3", "src/nav/cmd", "src/cmd"},
+		{"-2..-1", "src/nav/cmd", "nav/cmd"},
+		// fields the path doesn't have select nothing, rather than everything
+		{"5", "a/b", ""},
+		{"3..", "a/b", ""},
+	} {
+		if got := testFields(t, tt.spec).apply(tt.path); got != tt.want {
+			t.Errorf("%s of %q: got %q, want %q", tt.spec, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestFieldsApplyLines(t *testing.T) {
+	defer func(stdin bool) { *fromStdin = stdin }(*fromStdin)
+	*fromStdin = true
+
+	// lines read in split on runs of whitespace
+	if got := testFields(t, "2..").apply("  1234  main.go   fix it"); got != "main.go fix it" {
+		t.Errorf("got %q, want %q", got, "main.go fix it")
+	}
+	if got := testFields(t, "4").apply("a b c"); got != "" {
+		t.Errorf("a missing field gave %q, want nothing", got)
+	}
+
+	defer func(delim string) { *fieldDelim = delim }(*fieldDelim)
+	*fieldDelim = ":"
+	if got := testFields(t, "1,3").apply("main.go:12:func main() {"); got != "main.go:func main() {" {
+		t.Errorf("got %q, want %q", got, "main.go:func main() {")
+	}
+}
+
+func TestAcceptFields(t *testing.T) {
+	defer func(stdin bool) { *fromStdin = stdin }(*fromStdin)
+	*fromStdin = true
+	defer func(f fieldsFlag) { *acceptFields = f }(*acceptFields)
+	*acceptFields = *testFields(t, "1")
+
+	if got := formatResult("main.go 12 func main"); got != "main.go" {
+		t.Errorf("printed %q, want the first field, main.go", got)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"sync"
+)
+
+var includeFiles = flag.Bool("files", false, "index regular files as well as directories")
+
+// fileSet records which indexed paths are -files files rather than
+// directories, as the walk found them.
+type fileSet struct {
+	mu    sync.Mutex
+	files map[string]bool
+}
+
+var indexedFiles = &fileSet{files: map[string]bool{}}
+
+func (s *fileSet) Add(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[path] = true
+}
+
+func (s *fileSet) Has(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.files[path]
+}
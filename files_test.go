@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestReadirsFiles(t *testing.T) {
+	defer func(files bool) { *includeFiles = files }(*includeFiles)
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+	os.Mkdir(filepath.Join(dir, "src"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "main.go"), nil, 0644)
+
+	for _, files := range []bool{false, true} {
+		*includeFiles = files
+		batches := make(chan []string, 1)
+		descend := readirs(dir, batches)
+		close(batches)
+		var listed []string
+		for batch := range batches {
+			listed = append(listed, batch...)
+		}
+		sort.Strings(listed)
+
+		want := []string{filepath.Join(dir, "src")}
+		if files {
+			want = []string{filepath.Join(dir, "main.go"), filepath.Join(dir, "src")}
+		}
+		if !reflect.DeepEqual(listed, want) {
+			t.Errorf("-files=%v: listed %v, want %v", files, listed, want)
+		}
+		if d := []string{filepath.Join(dir, "src")}; !reflect.DeepEqual(descend, d) {
+			t.Errorf("-files=%v: descending into %v, want %v", files, descend, d)
+		}
+	}
+	if !indexedFiles.Has(filepath.Join(dir, "main.go")) || indexedFiles.Has(filepath.Join(dir, "src")) {
+		t.Error("main.go should be recorded as a file, and src not")
+	}
+}
+
+// TestFilesIgnores checks that the built-in list, which names directories,
+// doesn't hide -files files that share a name, while -exclude still does.
+func TestFilesIgnores(t *testing.T) {
+	defer func(files bool) { *includeFiles = files }(*includeFiles)
+	*includeFiles = true
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+	os.Mkdir(filepath.Join(dir, "vendor"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "build"), nil, 0644)
+	ioutil.WriteFile(filepath.Join(dir, "debug.log"), nil, 0644)
+
+	defer setIgnores(t, dir, "glob", false, "*.log")()
+	batches := make(chan []string, 1)
+	readirs(dir, batches)
+	close(batches)
+	var listed []string
+	for batch := range batches {
+		listed = append(listed, batch...)
+	}
+	if want := []string{filepath.Join(dir, "build")}; !reflect.DeepEqual(listed, want) {
+		t.Errorf("listed %v, want %v without vendor or the excluded log", listed, want)
+	}
+
+	// gitignore's dir/ only names directories too
+	defer setIgnores(t, dir, "gitignore", true, "out/", "*.tmp")()
+	for _, tt := range []struct {
+		path      string
+		dir, file bool
+	}{
+		{"out", true, false},
+		{"a.tmp", true, true},
+		{"src", false, false},
+	} {
+		path := filepath.Join(dir, tt.path)
+		if ignored(path) != tt.dir || ignoredFile(path) != tt.file {
+			t.Errorf("%s: ignored as a directory %v and as a file %v, want %v and %v", tt.path, ignored(path), ignoredFile(path), tt.dir, tt.file)
+		}
+	}
+}
+
+func TestToggleDirsOnly(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	indexedFiles.Add("/base/src/main.go")
+	indexedFiles.Add("/base/README.md")
+	b := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	b.setIndex([]string{"/base/src", "/base/src/main.go", "/base/README.md", "/base/docs"})
+
+	b.Recalculate()
+	if len(b.matches) != 4 {
+		t.Fatalf("got %v, want all four paths", b.matches)
+	}
+	b.ToggleDirsOnly()
+	if want := []string{"/base/src", "/base/docs"}; !reflect.DeepEqual(b.matches, want) {
+		t.Errorf("dirs only: got %v, want %v", b.matches, want)
+	}
+	b.ToggleDirsOnly()
+	if len(b.matches) != 4 {
+		t.Errorf("toggled back: got %v, want all four paths", b.matches)
+	}
+}
@@ -3,6 +3,8 @@ module github.com/kevin-cantwell/nav
 go 1.12
 
 require (
-	github.com/mattn/go-runewidth v0.0.4 // indirect
+	github.com/fsnotify/fsnotify v1.4.9
+	github.com/mattn/go-runewidth v0.0.4
 	github.com/nsf/termbox-go v0.0.0-20190325093121-288510b9734e
+	golang.org/x/text v0.3.2
 )
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"time"
+)
+
+var heartbeat = flag.Duration("heartbeat", 0, "also repaint the whole screen this often, recovering from anything else drawing over it (0, the default, repaints only on resume and resize)")
+
+// runHeartbeat repaints whenever nav is brought back to the foreground, and
+// every -heartbeat if set, until quit is closed. termbox only writes the cells
+// it thinks have changed, so without this a screen clobbered by a suspended
+// job or a multiplexer would stay garbled until those cells next change.
+func runHeartbeat() {
+	resumed := make(chan os.Signal, 1)
+	if len(resumeSignals) > 0 {
+		signal.Notify(resumed, resumeSignals...)
+		defer signal.Stop(resumed)
+	}
+	var tick <-chan time.Time
+	if *heartbeat > 0 {
+		ticker := time.NewTicker(*heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-tick:
+		case <-resumed:
+		}
+		repaint()
+	}
+}
+
+// repaint draws everything from scratch rather than only what changed.
+func repaint() {
+	drawMutex.Lock()
+	defer drawMutex.Unlock()
+
+	if drawClosed {
+		return
+	}
+	render(true)
+}
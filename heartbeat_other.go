@@ -0,0 +1,9 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+import "os"
+
+// resumeSignals is empty here, leaving the heartbeat alone to repaint.
+var resumeSignals []os.Signal
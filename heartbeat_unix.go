@@ -0,0 +1,12 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// resumeSignals are sent when nav is brought back to the foreground.
+var resumeSignals = []os.Signal{syscall.SIGCONT}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+var (
+	height   = flag.String("height", "", "draw in N rows, or N% of the terminal, below the cursor instead of over the whole screen")
+	sizeFlag = flag.String("size", "", "lay out for a WxH terminal instead of the size it reports (COLUMNS and LINES also override it)")
+)
+
+// minHeight fits the search box, the status row and one result.
+const minHeight = 5
+
+var (
+	heightRows    int
+	heightPercent bool
+
+	// sizeColumns and sizeLines override the reported size when nonzero
+	sizeColumns int
+	sizeLines   int
+)
+
+// initHeight validates -height, and -inline along with it.
+func initHeight() error {
+	if *height == "" {
+		return checkInline()
+	}
+	value := strings.TrimSuffix(*height, "%")
+	heightPercent = value != *height
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 || heightPercent && n > 100 {
+		return fmt.Errorf("invalid -height %q: want N or N%%", *height)
+	}
+	heightRows = n
+	return checkInline()
+}
+
+var errNoInline = errors.New("-height and -inline aren't supported on this platform")
+
+// checkInline rejects -height and -inline where they can't be drawn.
+func checkInline() error {
+	if inlineView() && !inlineSupported {
+		return errNoInline
+	}
+	return nil
+}
+
+// initSize reads the size override from -size or, failing that, from COLUMNS
+// and LINES, each of which overrides just its own dimension.
+func initSize() error {
+	if *sizeFlag != "" {
+		parts := strings.Split(*sizeFlag, "x")
+		if len(parts) == 2 {
+			w, werr := strconv.Atoi(parts[0])
+			h, herr := strconv.Atoi(parts[1])
+			if werr == nil && herr == nil && w > 0 && h > 0 {
+				sizeColumns, sizeLines = w, h
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid -size %q: want WxH", *sizeFlag)
+	}
+	// unlike a bad -size, a stray variable shouldn't stop nav starting
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		sizeColumns = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil && n > 0 {
+		sizeLines = n
+	}
+	return nil
+}
+
+// termSize is the terminal size, after any override. Everything that lays
+// out the screen goes through it rather than asking termbox.
+func termSize() (w, h int) {
+	if screen != nil {
+		// termbox only notices a resize when it draws, which it doesn't here
+		w, h = screen.w, screen.h
+	} else {
+		w, h = termbox.Size()
+	}
+	if sizeColumns > 0 {
+		w = sizeColumns
+	}
+	if sizeLines > 0 {
+		h = sizeLines
+	}
+	return w, h
+}
+
+// viewSize is the size of the region nav draws in. Every box lays itself out
+// from row 0 of it; the mouse handling shifts by viewTop.
+func viewSize() (w, h int) {
+	w, h = termSize()
+	if screen != nil {
+		h = screen.rows
+	}
+	return w, h
+}
+
+// viewTop is the terminal row the view starts on.
+func viewTop() int {
+	if screen == nil {
+		return 0
+	}
+	return screen.top
+}
+
+// viewRows is how many of a terminal's h rows a -height or -inline view
+// takes.
+func viewRows(h int) int {
+	// -inline needs no more than the minimum unless -height asks for it
+	rows := heightRows
+	if heightRows == 0 {
+		rows = minHeight
+	} else if heightPercent {
+		rows = h * heightRows / 100
+	}
+	if rows < minHeight {
+		rows = minHeight
+	}
+	if rows > h {
+		rows = h
+	}
+	return rows
+}
+
+// minWidth leaves room for a few characters of query and path.
+const minWidth = 10
+
+// tooSmall reports whether the view can't fit the search box and a row of
+// results.
+func tooSmall() bool {
+	w, h := viewSize()
+	return w < minWidth || h < viewLayout().results+1
+}
+
+// drawTooSmall replaces the whole view with a note to enlarge the terminal,
+// until the next resize.
+func drawTooSmall() {
+	w, h := viewSize()
+	msg := "terminal too small"
+	if textWidth([]rune(msg)) > w {
+		msg = "too small"
+	}
+	drawText(0, h/2, w, msg, termbox.AttrBold, termbox.ColorDefault)
+	hideCursor()
+}
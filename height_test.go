@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestInitSize(t *testing.T) {
+	defer func(size, columns, lines string) {
+		*sizeFlag = size
+		os.Setenv("COLUMNS", columns)
+		os.Setenv("LINES", lines)
+		sizeColumns, sizeLines = 0, 0
+	}(*sizeFlag, os.Getenv("COLUMNS"), os.Getenv("LINES"))
+	os.Setenv("COLUMNS", "")
+	os.Setenv("LINES", "")
+
+	for _, tt := range []struct {
+		size, columns, lines string
+		w, h                 int
+		bad                  bool
+	}{
+		{size: "100x30", w: 100, h: 30},
+		// -size wins over the environment
+		{size: "100x30", columns: "50", lines: "10", w: 100, h: 30},
+		// each variable overrides its own dimension
+		{columns: "50", w: 50, h: testH},
+		{lines: "10", w: testW, h: 10},
+		// a stray variable is ignored
+		{columns: "wide", w: testW, h: testH},
+		{size: "100", bad: true},
+		{size: "0x30", bad: true},
+	} {
+		*sizeFlag = tt.size
+		os.Setenv("COLUMNS", tt.columns)
+		os.Setenv("LINES", tt.lines)
+		sizeColumns, sizeLines = 0, 0
+		err := initSize()
+		if tt.bad {
+			if err == nil {
+				t.Errorf("-size %q accepted", tt.size)
+			}
+			continue
+		}
+		if w, h := termSize(); err != nil || w != tt.w || h != tt.h {
+			t.Errorf("%+v: %dx%d, %v; want %dx%d", tt, w, h, err, tt.w, tt.h)
+		}
+	}
+}
+
+func TestTooSmall(t *testing.T) {
+	defer func(rows int) { screen.rows = rows }(screen.rows)
+	defer func(hide bool) { *hideStatus = hide }(*hideStatus)
+	defer func(w int) { screen.w = w }(screen.w)
+
+	for _, tt := range []struct {
+		rows, w int
+		hide    bool
+		want    bool
+	}{
+		{rows: testH},
+		// the search box, divider, status row and a result
+		{rows: 5},
+		{rows: 4, want: true},
+		{rows: 4, hide: true},
+		{rows: 3, hide: true, want: true},
+		{rows: testH, w: minWidth - 1, want: true},
+		{rows: testH, w: minWidth},
+	} {
+		if tt.w == 0 {
+			tt.w = testW
+		}
+		screen.rows, screen.w, *hideStatus = tt.rows, tt.w, tt.hide
+		if got := tooSmall(); got != tt.want {
+			t.Errorf("%+v: too small is %v", tt, got)
+		}
+	}
+
+	screen.rows, screen.w, *hideStatus = 4, testW, false
+	clearScreen()
+	drawTooSmall()
+	if got := screenRow(2); got != "terminal too small" {
+		t.Errorf("row 2 is %q, want the note", got)
+	}
+
+	screen.w = minWidth - 1
+	clearScreen()
+	drawTooSmall()
+	if got := screenRow(2); got != "too small" {
+		t.Errorf("row 2 is %q, want the short note for a narrow terminal", got)
+	}
+}
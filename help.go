@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nsf/termbox-go"
+)
+
+// helpBox lists the key bindings over the results, a page at a time.
+type helpBox struct {
+	visible bool
+	page    int
+
+	mu sync.Mutex
+}
+
+var help = &helpBox{}
+
+func (b *helpBox) Show() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.visible = true
+	b.page = 0
+}
+
+func (b *helpBox) Visible() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.visible
+}
+
+// Advance moves to the next page, closing the overlay after the last one.
+func (b *helpBox) Advance() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.page++
+	if b.page >= b.pages() {
+		b.visible = false
+	}
+}
+
+// rows is how many bindings fit on a page, leaving room for the footer.
+func (b *helpBox) rows() int {
+	rows := resultRows() - 1
+	if rows < 1 {
+		rows = 1
+	}
+	return rows
+}
+
+func (b *helpBox) pages() int {
+	return (len(helpLines()) + b.rows() - 1) / b.rows()
+}
+
+func (b *helpBox) Draw() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.visible {
+		return
+	}
+
+	w, h := viewSize()
+	top := viewLayout().results
+	for y := top; y < h; y++ {
+		for x := 0; x < w; x++ {
+			setCell(x, y, ' ', termbox.ColorDefault, termbox.ColorDefault)
+		}
+	}
+
+	lines := helpLines()
+	start := b.page * b.rows()
+	end := start + b.rows()
+	if end > len(lines) {
+		end = len(lines)
+	}
+	for y, line := range lines[start:end] {
+		drawText(2, y+top, w, line, termbox.ColorDefault, termbox.ColorDefault)
+	}
+
+	footer := "press any key to close"
+	if pages := b.pages(); b.page < pages-1 {
+		footer = fmt.Sprintf("page %d/%d, press any key for more", b.page+1, pages)
+	}
+	drawText(2, h-1, w, footer, termbox.AttrBold, termbox.ColorDefault)
+}
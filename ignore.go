@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultIgnores are directory names that are rarely worth navigating to.
+var defaultIgnores = []string{
+	"node_modules",
+	".git",
+	"vendor",
+	"target",
+	"build",
+	"dist",
+	"__pycache__",
+	".venv",
+}
+
+var (
+	noDefaultIgnores = flag.Bool("no-default-ignores", false, "don't skip commonly uninteresting directories like node_modules and .git")
+	ignoreMode       = flag.String("ignore-mode", "exact", "how -exclude and .navignore patterns match: exact base names, glob base names, or gitignore rules (which also reads .gitignore)")
+	excludes         stringsFlag
+)
+
+func init() {
+	flag.Var(&excludes, "exclude", "skip directories matching this pattern, per -ignore-mode (repeatable)")
+}
+
+// stringsFlag collects every occurrence of a repeatable flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// ignoreRule is one pattern from any ignore source.
+type ignoreRule struct {
+	pattern string
+	// negate (gitignore only) un-ignores what earlier rules ignored
+	negate bool
+	// anchored (gitignore only) patterns match the whole path relative to the
+	// basepath rather than any base name
+	anchored bool
+	// dirOnly rules, the built-in list and gitignore's dir/, never skip a
+	// -files file
+	dirOnly bool
+}
+
+var (
+	ignoreRules []ignoreRule
+	ignoreBase  string
+)
+
+// initIgnores gathers the patterns to skip, in order of precedence from low
+// to high: the built-in list (unless -no-default-ignores), basepath's
+// .gitignore (gitignore mode only), its .navignore, and -exclude. In exact and
+// glob modes any match skips a directory, so the order doesn't matter; in
+// gitignore mode the last matching rule wins, so a later !pattern can bring
+// back what an earlier source skipped.
+func initIgnores(basepath string) error {
+	switch *ignoreMode {
+	case "exact", "glob", "gitignore":
+	default:
+		return fmt.Errorf("invalid -ignore-mode %q: want exact, glob or gitignore", *ignoreMode)
+	}
+	ignoreBase = basepath
+	ignoreRules = nil
+	if !*noDefaultIgnores {
+		for _, name := range defaultIgnores {
+			ignoreRules = append(ignoreRules, ignoreRule{pattern: name, dirOnly: true})
+		}
+	}
+	if *ignoreMode == "gitignore" {
+		addIgnores(readNavignore(filepath.Join(basepath, ".gitignore")))
+	}
+	addIgnores(readNavignore(filepath.Join(basepath, ".navignore")))
+	addIgnores(excludes)
+	return nil
+}
+
+func addIgnores(patterns []string) {
+	for _, pattern := range patterns {
+		ignoreRules = append(ignoreRules, parseIgnoreRule(pattern))
+	}
+}
+
+// parseIgnoreRule reads pattern as -ignore-mode says to. Only gitignore mode
+// gives ! and / any meaning.
+func parseIgnoreRule(pattern string) ignoreRule {
+	if *ignoreMode != "gitignore" {
+		return ignoreRule{pattern: pattern}
+	}
+	var rule ignoreRule
+	switch {
+	case strings.HasPrefix(pattern, "!"):
+		rule.negate = true
+		pattern = pattern[1:]
+	case strings.HasPrefix(pattern, `\!`):
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	rule.anchored = strings.Contains(pattern, "/")
+	rule.pattern = strings.TrimPrefix(pattern, "/")
+	return rule
+}
+
+// readNavignore reads one pattern per line, skipping blank lines and #
+// comments. Outside gitignore mode patterns are base names, so lines holding
+// a separator are reported on stderr and skipped. A missing file is fine.
+func readNavignore(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			warnf("reading %s: %v", path, err)
+		}
+		return nil
+	}
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if *ignoreMode != "gitignore" && (strings.ContainsRune(line, filepath.Separator) || strings.ContainsRune(line, '/')) {
+			warnf("%s:%d: %q is not a base name, ignoring it", path, n, line)
+			continue
+		}
+		names = append(names, line)
+	}
+	if err := scanner.Err(); err != nil {
+		warnf("reading %s: %v", path, err)
+	}
+	return names
+}
+
+// ignored reports whether the directory at path should be skipped.
+func ignored(path string) bool {
+	return skipped(path, false)
+}
+
+// ignoredFile reports whether the -files file at path should be skipped. The
+// built-in list names directories, so a file called build or vendor is kept.
+func ignoredFile(path string) bool {
+	return skipped(path, true)
+}
+
+func skipped(path string, file bool) bool {
+	name := filepath.Base(path)
+	switch *ignoreMode {
+	case "glob":
+		for _, rule := range ignoreRules {
+			if rule.dirOnly && file {
+				continue
+			}
+			if ok, _ := filepath.Match(rule.pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	case "gitignore":
+		rel, err := filepath.Rel(ignoreBase, path)
+		if err != nil {
+			rel = name
+		}
+		rel = filepath.ToSlash(rel)
+		var skip bool
+		for _, rule := range ignoreRules {
+			if rule.dirOnly && file {
+				continue
+			}
+			if rule.matches(rel, name) {
+				skip = !rule.negate
+			}
+		}
+		return skip
+	}
+	for _, rule := range ignoreRules {
+		if rule.pattern == name && !(rule.dirOnly && file) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches applies a gitignore rule to a directory, given its slash-separated
+// path relative to the basepath and its base name.
+func (rule ignoreRule) matches(rel, name string) bool {
+	if !rule.anchored {
+		ok, _ := filepath.Match(rule.pattern, name)
+		return ok
+	}
+	return matchSegments(strings.Split(rule.pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where ** stands
+// for any number of whole segments, including none.
+func matchSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
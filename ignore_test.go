@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// setIgnores runs initIgnores for basepath under the given flags, returning
+// a func that puts the flags back.
+func setIgnores(t *testing.T, basepath, mode string, noDefaults bool, exclude ...string) func() {
+	savedMode, savedNoDefaults, savedExcludes := *ignoreMode, *noDefaultIgnores, excludes
+	savedRules, savedBase := ignoreRules, ignoreBase
+	*ignoreMode, *noDefaultIgnores, excludes = mode, noDefaults, exclude
+	if err := initIgnores(basepath); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		*ignoreMode, *noDefaultIgnores, excludes = savedMode, savedNoDefaults, savedExcludes
+		ignoreRules, ignoreBase = savedRules, savedBase
+	}
+}
+
+func TestDefaultIgnores(t *testing.T) {
+	defer setIgnores(t, "/base", "exact", false, "fixtures")()
+	for path, want := range map[string]bool{
+		"/base/node_modules":      true,
+		"/base/src/.git":          true,
+		"/base/fixtures":          true,
+		"/base/src":               false,
+		"/base/node_modules_docs": false,
+	} {
+		if got := ignored(filepath.FromSlash(path)); got != want {
+			t.Errorf("ignored(%s) = %v, want %v", path, got, want)
+		}
+	}
+
+	defer setIgnores(t, "/base", "exact", true, "fixtures")()
+	if ignored(filepath.FromSlash("/base/node_modules")) {
+		t.Error("node_modules ignored despite -no-default-ignores")
+	}
+	if !ignored(filepath.FromSlash("/base/fixtures")) {
+		t.Error("-exclude fixtures not ignored with -no-default-ignores")
+	}
+}
+
+func TestNavignore(t *testing.T) {
+	path := writeConfig(t, ".navignore", "# generated\nfixtures\n\n  testdata  \nsrc/gen\n")
+	dir := filepath.Dir(path)
+	defer os.RemoveAll(dir)
+
+	var got []string
+	out := captureStderr(t, func() { got = readNavignore(path) })
+	if want := []string{"fixtures", "testdata"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read %q, want %q without the comment, blank line or path", got, want)
+	}
+	if want := "nav: " + path + ":5: \"src/gen\" is not a base name, ignoring it\n"; out != want {
+		t.Errorf("printed %q, want %q", out, want)
+	}
+	if got := readNavignore(filepath.Join(dir, "missing")); got != nil {
+		t.Errorf("a missing file gave %q", got)
+	}
+
+	var restore func()
+	captureStderr(t, func() { restore = setIgnores(t, dir, "exact", true) })
+	defer restore()
+	if !ignored(filepath.Join(dir, "a", "testdata")) || ignored(filepath.Join(dir, "src")) {
+		t.Error("the .navignore names weren't applied")
+	}
+}
+
+func TestIgnoreModes(t *testing.T) {
+	base := filepath.FromSlash("/base")
+	for _, tt := range []struct {
+		mode    string
+		exclude []string
+		path    string
+		want    bool
+	}{
+		{"exact", []string{"tmp*"}, "tmp1", false},
+		{"exact", []string{"tmp*"}, "tmp*", true},
+		{"glob", []string{"tmp*"}, "src/tmp1", true},
+		{"glob", []string{"tmp*"}, "src/temp", false},
+		// gitignore patterns with a / match from the basepath
+		{"gitignore", []string{"/build"}, "build", true},
+		{"gitignore", []string{"/build"}, "src/build", false},
+		{"gitignore", []string{"docs/**/gen"}, "docs/api/v1/gen", true},
+		{"gitignore", []string{"docs/**/gen"}, "docs/gen", true},
+		{"gitignore", []string{"docs/**/gen"}, "src/docs/gen", false},
+		// and a later !pattern brings back what an earlier one skipped
+		{"gitignore", []string{"gen*", "!generics"}, "src/generics", false},
+		{"gitignore", []string{"gen*", "!generics"}, "src/generated", true},
+	} {
+		restore := setIgnores(t, base, tt.mode, true, tt.exclude...)
+		if got := ignored(filepath.Join(base, filepath.FromSlash(tt.path))); got != tt.want {
+			t.Errorf("-ignore-mode %s %q: ignored(%s) = %v, want %v", tt.mode, tt.exclude, tt.path, got, tt.want)
+		}
+		restore()
+	}
+
+	defer func(mode string) { *ignoreMode = mode }(*ignoreMode)
+	*ignoreMode = "regex"
+	if err := initIgnores(base); err == nil {
+		t.Error("-ignore-mode regex accepted")
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/*", "a/b", true},
+		{"a/*", "a/b/c", false},
+		{"**/c", "c", true},
+		{"**/c", "a/b/c", true},
+		{"a/**", "a/b/c", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/y/c", false},
+	} {
+		if got := matchSegments(strings.Split(tt.pattern, "/"), strings.Split(tt.path, "/")); got != tt.want {
+			t.Errorf("matchSegments(%s, %s) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/nsf/termbox-go"
+)
+
+// termbox has no Shift-Tab, so -inline cycles forward with Tab and back with
+// Up, as Down also moves forward.
+var inline = flag.Bool("inline", false, "list results side by side on a single row, drawn below the cursor; Tab cycles through them")
+
+// inlineGap separates results on the -inline row.
+const inlineGap = "  "
+
+// drawInline draws the matches side by side on row y, scrolling so the
+// selection is always whole, with … marking matches cut off at either end.
+// b.mu must be held.
+func (b *resultsBox) drawInline(y, w int) {
+	// room between the … columns at each edge
+	left, right := 2, w-2
+	if b.selected < b.inlineStart {
+		b.inlineStart = b.selected
+	}
+	for b.inlineStart < b.selected && b.inlineWidth(b.inlineStart, b.selected) > right-left {
+		b.inlineStart++
+	}
+	if b.inlineStart < 0 {
+		b.inlineStart = 0
+	}
+	if b.inlineStart > 0 {
+		setCell(0, y, '…', termbox.ColorDefault, termbox.ColorDefault)
+	}
+
+	x := left
+	for i := b.inlineStart; i < len(b.matches); i++ {
+		label := []rune(b.label(i))
+		if i > b.inlineStart {
+			x += len(inlineGap)
+		}
+		if x+textWidth(label) > right && i != b.selected {
+			setCell(w-1, y, '…', termbox.ColorDefault, termbox.ColorDefault)
+			return
+		}
+		fg := termbox.ColorDefault
+		if i == b.selected {
+			fg = selectedStyle()
+		}
+		x = drawText(x, y, right, string(label), fg, termbox.ColorDefault)
+	}
+}
+
+// inlineWidth is how many columns matches from through to take side by side.
+func (b *resultsBox) inlineWidth(from, to int) int {
+	width := len(inlineGap) * (to - from)
+	for i := from; i <= to; i++ {
+		width += textWidth([]rune(b.label(i)))
+	}
+	return width
+}
+
+// CycleSelection moves the selection to the next match, wrapping around to the
+// first after the last.
+func (b *resultsBox) CycleSelection() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.matches) == 0 {
+		return
+	}
+	b.selected = (b.selected + 1) % len(b.matches)
+	b.scrollToSelected()
+}
@@ -0,0 +1,36 @@
+package main
+
+// ToggleInvert switches between listing the paths that match the query and
+// those that don't.
+func (b *resultsBox) ToggleInvert() {
+	b.mu.Lock()
+	b.inverted = !b.inverted
+	b.mu.Unlock()
+
+	b.Recalculate()
+}
+
+func (b *resultsBox) Inverted() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.inverted
+}
+
+// nonMatching returns the indexed paths that don't match m, shortest
+// first since they have no score to rank them by. Every path matches an empty
+// query, and the basepath is left out as it is from any other query's matches.
+// b.mu must be held.
+func (b *resultsBox) nonMatching(m matcher) []string {
+	if len(m.value) == 0 {
+		return nil
+	}
+	var paths []string
+	for i, path := range b.filepaths {
+		if path != search.basepath && m.scoreIndexed(path, b.basenames[i]) == 0 {
+			paths = append(paths, path)
+		}
+	}
+	sortByLength(paths)
+	return paths
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToggleInvert(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	defer setQuery("")
+	b := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	paths := []string{"/base", "/base/docs", "/base/src/api", "/base/src", "/base/lib"}
+	sortByScore(paths, testMatcher("", false))
+	b.setIndex(paths)
+
+	setQuery("src")
+	b.ToggleInvert()
+	if !b.Inverted() {
+		t.Fatal("not inverted after Alt-I")
+	}
+	// shortest first, leaving out the basepath
+	if want := []string{"/base/lib", "/base/docs"}; !reflect.DeepEqual(b.matches, want) {
+		t.Errorf("inverted src: got %v, want %v", b.matches, want)
+	}
+
+	// every path matches an empty query
+	setQuery("")
+	b.Recalculate()
+	if len(b.matches) != 0 {
+		t.Errorf("inverted empty query: got %v, want nothing", b.matches)
+	}
+
+	setQuery("src")
+	b.ToggleInvert()
+	if want := []string{"/base/src", "/base/src/api"}; b.Inverted() || !reflect.DeepEqual(b.matches, want) {
+		t.Errorf("toggled back: got %v, want %v", b.matches, want)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+var jumpChars = flag.String("jump-labels", "asdfghjklqwertyuiopzxcvbnm", "characters Alt-J labels the visible rows with, one each or in pairs when there are more rows")
+
+// jumpLabels returns n distinct labels. They are all one character long when
+// there are enough characters, and otherwise all two, so no label is a prefix
+// of another.
+func jumpLabels(n int) []string {
+	chars := []rune(*jumpChars)
+	labels := make([]string, 0, n)
+	if n <= len(chars) {
+		for _, c := range chars[:n] {
+			labels = append(labels, string(c))
+		}
+		return labels
+	}
+	for _, first := range chars {
+		for _, second := range chars {
+			if len(labels) == n {
+				return labels
+			}
+			labels = append(labels, string(first)+string(second))
+		}
+	}
+	return labels
+}
+
+// StartJump labels each visible row, until the next keys pick one by its label.
+func (b *resultsBox) StartJump() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// -inline has no rows to label
+	if *inline || len(b.matches) == 0 {
+		return
+	}
+	b.jumping, b.jumpTyped = true, ""
+}
+
+func (b *resultsBox) Jumping() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.jumping
+}
+
+func (b *resultsBox) CancelJump() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.jumping = false
+}
+
+// Jump types ch towards a label, reporting whether it completed one, in which
+// case that row is now selected. A key that fits no label cancels the jump.
+func (b *resultsBox) Jump(ch rune) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.jumpTyped += string(ch)
+	start, end := b.visibleRange()
+	var partial bool
+	for i, label := range jumpLabels(end - start) {
+		if label == b.jumpTyped {
+			b.selected = start + i
+			b.jumping = false
+			return true
+		}
+		if strings.HasPrefix(label, b.jumpTyped) {
+			partial = true
+		}
+	}
+	b.jumping = partial
+	return false
+}
+
+// drawJumpLabels draws the label of each visible row over its selection
+// marker, leaving out those the keys typed so far rule out. b.mu must be held.
+func (b *resultsBox) drawJumpLabels() {
+	if !b.jumping {
+		return
+	}
+	top := viewLayout().results
+	start, end := b.visibleRange()
+	for i, label := range jumpLabels(end - start) {
+		if !strings.HasPrefix(label, b.jumpTyped) {
+			continue
+		}
+		drawText(0, i+top, 2, label, termbox.AttrReverse|termbox.AttrBold, termbox.ColorDefault)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// binding maps a key, or an Alt-modified rune, to an event.
+type binding struct {
+	key  termbox.Key
+	ch   rune
+	mod  termbox.Modifier
+	ev   evType
+	help string
+}
+
+// keymap is consulted in order by pollEvents and listed by the help overlay.
+// Printable runes without a binding are inserted into the query.
+var keymap = []binding{
+	{key: termbox.KeyEnter, ev: EventSelected, help: "select the highlighted path"},
+	{key: termbox.KeyEnter, mod: termbox.ModAlt, ev: EventSelectParent, help: "select the highlighted path's parent"},
+	{ch: 'o', mod: termbox.ModAlt, ev: EventReveal, help: "open the highlighted path in the file manager"},
+	{ch: 's', mod: termbox.ModAlt, ev: EventShell, help: "open a shell in the highlighted directory, returning here on exit"},
+	{key: termbox.KeyEsc, ev: EventShutdown, help: "cancel"},
+	{key: termbox.KeyCtrlC, ev: EventShutdown, help: "cancel"},
+	{key: termbox.KeyArrowDown, ev: EventMoveSelectionDownOne, help: "move the selection down (or to a newer query)"},
+	{key: termbox.KeyArrowUp, ev: EventMoveSelectionUpOne, help: "move the selection up (or to an older query)"},
+	{key: termbox.KeyArrowLeft, mod: termbox.ModAlt, ev: EventScrollLeft, help: "scroll results left"},
+	{key: termbox.KeyArrowRight, mod: termbox.ModAlt, ev: EventScrollRight, help: "scroll results right"},
+	{key: termbox.KeyArrowLeft, ev: EventMoveCursorBackwardOneRune, help: "move the cursor back"},
+	{key: termbox.KeyCtrlB, ev: EventMoveCursorBackwardOneRune, help: "move the cursor back"},
+	{key: termbox.KeyArrowRight, ev: EventMoveCursorForwardOneRune, help: "move the cursor forward"},
+	{key: termbox.KeyCtrlF, ev: EventMoveCursorForwardOneRune, help: "move the cursor forward"},
+	{ch: 'b', mod: termbox.ModAlt, ev: EventMoveCursorBackwardOneWord, help: "move the cursor back a word"},
+	{ch: 'f', mod: termbox.ModAlt, ev: EventMoveCursorForwardOneWord, help: "move the cursor forward a word"},
+	{key: termbox.KeyBackspace, mod: termbox.ModAlt, ev: EventDeleteWordBackward, help: "delete the previous word"},
+	{key: termbox.KeyBackspace2, mod: termbox.ModAlt, ev: EventDeleteWordBackward, help: "delete the previous word"},
+	{key: termbox.KeyBackspace, ev: EventDeleteRuneBackward, help: "delete the previous character"},
+	{key: termbox.KeyBackspace2, ev: EventDeleteRuneBackward, help: "delete the previous character"},
+	{key: termbox.KeyDelete, ev: EventDeleteRuneForward, help: "delete the next character"},
+	{key: termbox.KeyCtrlD, ev: EventDeleteRuneForward, help: "delete the next character"},
+	{key: termbox.KeyCtrlL, ev: EventClearQuery, help: "clear the whole query"},
+	{ch: 'c', mod: termbox.ModAlt, ev: EventCopyAbsolute, help: "copy the absolute path"},
+	{ch: 'r', mod: termbox.ModAlt, ev: EventCopyRelative, help: "copy the relative path"},
+	{ch: 'n', mod: termbox.ModAlt, ev: EventCopyBasename, help: "copy the base name"},
+	{key: termbox.KeyCtrlO, ev: EventActOnSelection, help: "act on the highlighted path without exiting (-act)"},
+	{key: termbox.KeyCtrlX, ev: EventExec, help: "run the -exec command on the highlighted path"},
+	{ch: 'a', mod: termbox.ModAlt, ev: EventToggleAbsolute, help: "show results as absolute or relative paths"},
+	{ch: 'd', mod: termbox.ModAlt, ev: EventToggleDirsOnly, help: "show only directories, hiding -files files and -symlinks links"},
+	{ch: 'm', mod: termbox.ModAlt, ev: EventToggleMatchBase, help: "match base names only, or whole paths"},
+	{key: termbox.KeyF2, ev: EventCycleScorer, help: "switch to the next -scorer"},
+	{ch: 'i', mod: termbox.ModAlt, ev: EventToggleInvert, help: "list the paths that don't match the query instead"},
+	{ch: 'p', mod: termbox.ModAlt, ev: EventTogglePin, help: "pin the highlighted path to the top of the list, or unpin it"},
+	{ch: '#', mod: termbox.ModAlt, ev: EventToggleScores, help: "show or hide each result's score"},
+	{ch: 'j', mod: termbox.ModAlt, ev: EventJump, help: "label the visible rows, then type a label to select that row"},
+	{key: termbox.KeyTab, ev: EventToggleGroup, help: "collapse or expand the highlighted group (-group), or cycle results (-inline)"},
+	{key: termbox.KeyCtrlR, ev: EventRefresh, help: "re-index changed directories"},
+	{key: termbox.KeyF5, ev: EventForceRefresh, help: "re-index everything"},
+	{key: termbox.KeyF1, ev: EventHelp, help: "show this help (also ? on an empty query)"},
+}
+
+var (
+	acceptKeys = flag.String("accept", "Enter", "comma-separated keys that select the highlighted path, e.g. Enter,Tab")
+	cancelKeys = flag.String("cancel", "Esc,Ctrl-C", "comma-separated keys that cancel (may be empty)")
+	ctrlCKills = flag.Bool("ctrl-c-cancels", true, "always cancel on Ctrl-C, whatever -accept and -cancel say")
+)
+
+// initKeys rebinds accepting and cancelling to the -accept and -cancel keys,
+// which take precedence over anything else bound to them.
+func initKeys() error {
+	accept, err := parseKeys(*acceptKeys, EventSelected, "select the highlighted path")
+	if err != nil {
+		return fmt.Errorf("invalid -accept: %v", err)
+	}
+	if len(accept) == 0 {
+		return fmt.Errorf("invalid -accept: no keys")
+	}
+	cancel, err := parseKeys(*cancelKeys, EventShutdown, "cancel")
+	if err != nil {
+		return fmt.Errorf("invalid -cancel: %v", err)
+	}
+	if kill := (binding{key: termbox.KeyCtrlC, ev: EventShutdown, help: "cancel"}); *ctrlCKills && !bound(cancel, kill) {
+		cancel = append(cancel, kill)
+	}
+	for _, a := range accept {
+		if bound(cancel, a) {
+			return fmt.Errorf("%s can't both accept and cancel", a.name())
+		}
+	}
+
+	rebound := append(accept, cancel...)
+	keys := append([]binding(nil), rebound...)
+	for _, b := range keymap {
+		if b.ev != EventSelected && b.ev != EventShutdown && !bound(rebound, b) {
+			keys = append(keys, b)
+		}
+	}
+	keymap = keys
+	return nil
+}
+
+// parseKeys reads comma-separated key names, as the help overlay shows them,
+// into bindings to ev.
+func parseKeys(names string, ev evType, help string) ([]binding, error) {
+	var bindings []binding
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		b, err := parseKey(name)
+		if err != nil {
+			return nil, err
+		}
+		b.ev, b.help = ev, help
+		bindings = append(bindings, b)
+	}
+	return bindings, nil
+}
+
+// parseKey reads a key name like Tab, Ctrl-G, Alt-q or Alt-Enter.
+func parseKey(name string) (binding, error) {
+	var b binding
+	rest := name
+	if strings.HasPrefix(rest, "Alt-") && len(rest) > len("Alt-") {
+		b.mod = termbox.ModAlt
+		rest = rest[len("Alt-"):]
+	}
+	for key, keyName := range keyNames {
+		if keyName == rest {
+			b.key = key
+			return b, nil
+		}
+	}
+	if r := []rune(rest); len(r) == 1 && b.mod == termbox.ModAlt {
+		b.ch = r[0]
+		return b, nil
+	}
+	if letter := strings.TrimPrefix(rest, "Ctrl-"); letter != rest && len(letter) == 1 {
+		if c := strings.ToLower(letter)[0]; c >= 'a' && c <= 'z' {
+			b.key = termbox.Key(c - 'a' + 1)
+			return b, nil
+		}
+	}
+	return binding{}, fmt.Errorf("unknown key %q", name)
+}
+
+// bound reports whether any of bindings is triggered by b's key.
+func bound(bindings []binding, b binding) bool {
+	for _, other := range bindings {
+		if other.key == b.key && other.ch == b.ch && other.mod == b.mod {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupBinding finds the binding for a key event.
+func lookupBinding(ev termbox.Event) (binding, bool) {
+	for _, b := range keymap {
+		if b.mod != ev.Mod {
+			continue
+		}
+		if b.ch != 0 && b.ch == ev.Ch || b.ch == 0 && ev.Ch == 0 && b.key == ev.Key {
+			return b, true
+		}
+	}
+	return binding{}, false
+}
+
+var keyNames = map[termbox.Key]string{
+	termbox.KeyEnter:      "Enter",
+	termbox.KeyEsc:        "Esc",
+	termbox.KeyCtrlC:      "Ctrl-C",
+	termbox.KeyCtrlB:      "Ctrl-B",
+	termbox.KeyCtrlF:      "Ctrl-F",
+	termbox.KeyCtrlD:      "Ctrl-D",
+	termbox.KeyCtrlR:      "Ctrl-R",
+	termbox.KeyCtrlL:      "Ctrl-L",
+	termbox.KeyCtrlO:      "Ctrl-O",
+	termbox.KeyTab:        "Tab",
+	termbox.KeyArrowUp:    "Up",
+	termbox.KeyArrowDown:  "Down",
+	termbox.KeyArrowLeft:  "Left",
+	termbox.KeyArrowRight: "Right",
+	termbox.KeyBackspace:  "Ctrl-H",
+	termbox.KeyBackspace2: "Backspace",
+	termbox.KeyDelete:     "Delete",
+	termbox.KeyF1:         "F1",
+	termbox.KeyF2:         "F2",
+	termbox.KeyF5:         "F5",
+}
+
+func (b binding) name() string {
+	name, ok := keyNames[b.key]
+	if !ok && b.key >= termbox.KeyCtrlA && b.key <= termbox.KeyCtrlZ {
+		name = "Ctrl-" + string(rune('A'+b.key-termbox.KeyCtrlA))
+	}
+	if b.ch != 0 {
+		name = string(b.ch)
+	}
+	if b.mod == termbox.ModAlt {
+		name = "Alt-" + name
+	}
+	return name
+}
+
+// helpLines describes each bound action once, with all of its keys.
+func helpLines() []string {
+	var order []string
+	keys := map[string][]string{}
+	for _, b := range keymap {
+		if _, ok := keys[b.help]; !ok {
+			order = append(order, b.help)
+		}
+		keys[b.help] = append(keys[b.help], b.name())
+	}
+
+	var width int
+	for _, help := range order {
+		if n := len(strings.Join(keys[help], ", ")); n > width {
+			width = n
+		}
+	}
+	var lines []string
+	for _, help := range order {
+		names := strings.Join(keys[help], ", ")
+		lines = append(lines, names+strings.Repeat(" ", width-len(names)+2)+help)
+	}
+	return lines
+}
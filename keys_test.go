@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+func TestParseKey(t *testing.T) {
+	// names read back as the help overlay shows them
+	for _, name := range []string{"Tab", "Enter", "Ctrl-G", "Alt-q", "Alt-Enter"} {
+		b, err := parseKey(name)
+		if err != nil || b.name() != name {
+			t.Errorf("%s: parsed as %q, %v", name, b.name(), err)
+		}
+	}
+	for _, bad := range []string{"", "q", "Hyper-x", "Ctrl-1", "Ctrl-", "Alt-"} {
+		if _, err := parseKey(bad); err == nil {
+			t.Errorf("%q parsed without error", bad)
+		}
+	}
+}
+
+func TestInitKeys(t *testing.T) {
+	defer func(keys []binding) { keymap = keys }(keymap)
+	defer func(accept, cancel string, kills bool) {
+		*acceptKeys, *cancelKeys, *ctrlCKills = accept, cancel, kills
+	}(*acceptKeys, *cancelKeys, *ctrlCKills)
+	defaults := keymap
+
+	*acceptKeys, *cancelKeys = "Tab, Alt-Enter", "Esc"
+	if err := initKeys(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		ev   termbox.Event
+		want evType
+	}{
+		// -accept takes Tab over from the group toggle
+		{termbox.Event{Key: termbox.KeyTab}, EventSelected},
+		{termbox.Event{Key: termbox.KeyEnter, Mod: termbox.ModAlt}, EventSelected},
+		{termbox.Event{Key: termbox.KeyEsc}, EventShutdown},
+		// Ctrl-C cancels whatever -cancel says
+		{termbox.Event{Key: termbox.KeyCtrlC}, EventShutdown},
+	} {
+		if b, _ := lookupBinding(tt.ev); b.ev != tt.want {
+			t.Errorf("%+v is bound to %v, want %v", tt.ev, b.ev, tt.want)
+		}
+	}
+	if b, ok := lookupBinding(termbox.Event{Key: termbox.KeyEnter}); ok && b.ev == EventSelected {
+		t.Error("Enter still accepts though -accept leaves it out")
+	}
+
+	for _, tt := range []struct{ accept, cancel string }{
+		{"", "Esc"},
+		{"Esc", "Esc"},
+		{"Enter", "Nonsense"},
+		// -ctrl-c-cancels adds Ctrl-C to the cancel keys
+		{"Ctrl-C", ""},
+	} {
+		keymap = defaults
+		*acceptKeys, *cancelKeys = tt.accept, tt.cancel
+		if err := initKeys(); err == nil {
+			t.Errorf("-accept %q -cancel %q accepted", tt.accept, tt.cancel)
+		}
+	}
+}
+
+// TestKeyNames checks every binding has a name for the help overlay, and one
+// that -accept and -cancel read back as the same key.
+func TestKeyNames(t *testing.T) {
+	for _, b := range keymap {
+		name := b.name()
+		if name == "" || strings.HasSuffix(name, "-") {
+			t.Errorf("%q has no key name", b.help)
+			continue
+		}
+		if parsed, err := parseKey(name); err != nil || !bound([]binding{parsed}, b) {
+			t.Errorf("%s, bound to %q, reads back as %+v, %v", name, b.help, parsed, err)
+		}
+	}
+	for _, line := range helpLines() {
+		if strings.HasPrefix(line, " ") {
+			t.Errorf("help line %q has no keys", line)
+		}
+	}
+}
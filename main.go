@@ -2,17 +2,26 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"os/user"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/nsf/termbox-go"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
 )
 
 type evType int
@@ -25,14 +34,38 @@ const (
 	EventDeleteRuneForward
 	EventDeleteRuneBackward
 	EventDeleteWordBackward
+	EventClearQuery
 	EventInsertRune
 	EventMoveSelectionDownOne
 	EventMoveSelectionUpOne
+	EventScrollLeft
+	EventScrollRight
+	EventCopyAbsolute
+	EventCopyRelative
+	EventCopyBasename
+	EventActOnSelection
+	EventExec
+	EventReveal
+	EventShell
+	EventToggleAbsolute
+	EventToggleDirsOnly
+	EventJump
+	EventToggleScores
+	EventTogglePin
+	EventToggleInvert
+	EventCycleScorer
+	EventToggleMatchBase
+	EventRefresh
+	EventForceRefresh
 	EventSelected
+	EventSelectParent
+	EventHelp
+	EventToggleGroup
 
 	EventMouseDrag
 	EventMousePress
 	EventMouseClick
+	EventMouseDoubleClick
 	EventMouseScrollDown
 	EventMouseScrollUp
 
@@ -48,41 +81,95 @@ type event struct {
 	err    error
 }
 
+var (
+	query       = flag.String("q", "", "start with this query")
+	selectIndex = flag.Int("select", -1, "preselect the Nth result (0-based) on startup")
+	resumeSel   = flag.Bool("resume-selection", false, "start on the path last selected from this basepath, if it is still there")
+	revealAt    = flag.String("reveal-path", "", "start on this path, or the best match ending with it, without filtering the list")
+	treeView    = flag.Bool("tree", false, "show results as a tree grouped by parent directory")
+	groupView   = flag.Bool("group", false, "group results under collapsible parent directory headers (Tab toggles)")
+	compact     = flag.Bool("compact", false, "drop the selection marker column, showing the selection by its style alone, to give paths the room")
+	selectStyle = flag.String("select-style", "default", "how to highlight the selected row: default (bold and underlined), reverse, or both")
+	placeholder = flag.String("placeholder", "type to filter…", "hint shown while the query is empty (empty for none)")
+	doubleClick = flag.Duration("double-click", 0, "choose a row with two clicks this close together, rather than one click on the selected row")
+	noColor     = flag.Bool("no-color", false, "render without colors (also enabled by setting NO_COLOR)")
+	maxPerDir   = flag.Int("max-per-dir", 0, "index at most N subdirectories of any one directory (0 means no limit)")
+	maxIndex    = flag.Int("max-index", 0, "index at most N paths in all, ignoring any found after that (0 means no limit)")
+	reveal      = flag.Bool("reveal", false, "open the selection in the file manager instead of printing it")
+	cancelOut   = flag.String("cancel-output", ".", "what to print when cancelled or nothing is selected (may be empty)")
+	noAccents   = flag.Bool("ignore-accents", false, "match accented characters against their unaccented forms (cafe matches café)")
+	dirOfSel    = flag.Bool("dir-of-selection", false, "print the containing directory when the selection is a file")
+	alignNotes  = flag.Bool("align-notes", false, "line up notes like symlink targets and (truncated) in a column after the paths")
+	baseMatch   = flag.Bool("match-basename", false, "match the query against the last segment of each path only (Alt-M toggles)")
+	baseOnly    = flag.Bool("basename", false, "print only the last element of the selected path")
+	shellQuote  = flag.Bool("shell-quote", false, "quote the selected path for pasting onto a POSIX shell command line")
+	actOn       = flag.String("act", "copy", "what Ctrl-O does with the selection, leaving nav open: copy, or fd:N to write it to file descriptor N")
+	jsonOut     = flag.Bool("json", false, "print every match for -q as JSON instead of starting the picker")
+	printAll    = flag.Bool("print-all", false, "print every match for -q, best first, one per line, instead of starting the picker (-accept-nth 1.. prints them relative to the basepath)")
+	print0      = flag.Bool("print0", false, "end each -print-all path with NUL rather than a newline")
+	lineNumbers = flag.Bool("numbers", false, "prefix each result with its 1-based index")
+	scrollOff   = flag.Int("scrolloff", 0, "keep N rows of context visible above and below the selection")
+	foldDisplay = flag.Bool("fold-display", false, "show paths in lowercase (output is unaffected)")
+	minQuery    = flag.Int("min-query", 0, "don't list results until the query has at least N characters")
+	sortMode    = flag.String("sort", "score", "rank matches by score, or shortest to put the shortest paths first with the score breaking ties")
+	noSelf      = flag.Bool("no-self", false, "don't list the base directory itself")
+	boundaryBon = flag.Float64("boundary-bonus", 0, "reward matches at the start of a path segment; separators then cost nothing to cross")
+)
+
+var extWeights = weightsFlag{}
+
+func init() {
+	flag.Var(extWeights, "ext-weight", "comma-separated ext=multiplier score weights, e.g. .go=2,.md=0.5")
+}
+
 var (
 	search = &searchBox{
-		basepath:      initBasepath(),
 		cursorOffsetX: 0,
 		cursorOffsetY: 0,
 		value:         []rune{},
+		historyIndex:  -1,
 	}
-	results = &resultsBox{}
-	debug   = &debugBox{
+	results = &resultsBox{
+		preselect: -1,
+		initDone:  make(chan struct{}),
+	}
+	debug = &debugBox{
 		buf: &bytes.Buffer{},
 	}
 )
 
-func initBasepath() (basepath string) {
+func initBasepath() (basepath string, err error) {
 	defer func() {
-		_, err := os.Stat(basepath)
 		if err != nil {
-			if os.IsNotExist(err) {
-				fmt.Println("no such file or directory")
-				os.Exit(1)
-			}
-			panic(err)
+			return
+		}
+		info, statErr := os.Stat(basepath)
+		if statErr != nil {
+			basepath, err = "", statErr
+			return
+		}
+		// a file argument navigates from the directory containing it, said
+		// on stderr, which is left showing once the picker exits
+		if !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "nav: %s is not a directory, using %s\n", basepath, filepath.Dir(basepath))
+			basepath = filepath.Dir(basepath)
 		}
 	}()
 
-	if len(os.Args) > 1 {
-		path, err := filepath.Abs(os.Args[1])
+	if flag.NArg() > 0 {
+		path, err := expandTilde(flag.Arg(0))
 		if err != nil {
-			panic(err)
+			return "", err
 		}
-		return path
+		path, err = filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Clean(path), nil
 	}
 	wd, err := os.Getwd()
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 	path := wd
 	for ; path != "/"; path = filepath.Dir(path) {
@@ -95,34 +182,239 @@ func initBasepath() (basepath string) {
 	if path == "/" {
 		path = wd
 	}
-	return path
+	return path, nil
+}
+
+// expandTilde replaces a leading ~ or ~user with that user's home directory,
+// for arguments that reach nav without passing through a shell.
+func expandTilde(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	name, rest := path[1:], ""
+	if i := strings.IndexRune(name, filepath.Separator); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+	if name == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return home + rest, nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", err
+	}
+	return u.HomeDir + rest, nil
 }
 
 func main() {
+	flag.Parse()
+
 	log.SetOutput(debug)
 	log.SetFlags(0)
 
-	if err := termbox.Init(); err != nil {
-		panic(err)
+	basepath, err := initBasepath()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "nav:", err)
+		os.Exit(1)
+	}
+	// the project's config can only be found once the basepath is known, and
+	// must be read before anything below looks at the flags
+	loadConfigs(basepath)
+
+	if err := initKeys(); err != nil {
+		fmt.Fprintln(os.Stderr, "nav:", err)
+		os.Exit(2)
+	}
+	if err := initAct(); err != nil {
+		fmt.Fprintln(os.Stderr, "nav:", err)
+		os.Exit(2)
+	}
+	if err := initHeight(); err != nil {
+		fmt.Fprintln(os.Stderr, "nav:", err)
+		os.Exit(2)
+	}
+	if err := initSize(); err != nil {
+		fmt.Fprintln(os.Stderr, "nav:", err)
+		os.Exit(2)
+	}
+	if err := initFrom(); err != nil {
+		fmt.Fprintln(os.Stderr, "nav:", err)
+		os.Exit(2)
+	}
+
+	if *sortMode != "score" && *sortMode != "shortest" {
+		fmt.Fprintf(os.Stderr, "nav: invalid -sort %q: want score or shortest\n", *sortMode)
+		os.Exit(2)
+	}
+	switch *outputOrder {
+	case "score", "path", "mtime":
+	default:
+		fmt.Fprintf(os.Stderr, "nav: invalid -order %q: want score, path or mtime\n", *outputOrder)
+		os.Exit(2)
+	}
+	if scorerIndex(*scorerName) < 0 {
+		fmt.Fprintf(os.Stderr, "nav: invalid -scorer %q: want %s\n", *scorerName, strings.Join(scorers, " or "))
+		os.Exit(2)
+	}
+	switch *selectStyle {
+	case "default", "reverse", "both":
+	default:
+		fmt.Fprintf(os.Stderr, "nav: invalid -select-style %q: want default, reverse or both\n", *selectStyle)
+		os.Exit(2)
+	}
+	// both want Tab
+	if *inline && *groupView {
+		fmt.Fprintln(os.Stderr, "nav: -inline and -group can't be used together")
+		os.Exit(2)
+	}
+
+	monochrome = *noColor || os.Getenv("NO_COLOR") != ""
+	search.basepath = basepath
+	search.scorer = scorerIndex(*scorerName)
+	search.matchBase = *baseMatch
+	search.value = []rune(*query)
+	search.cursorOffsetX = len(search.value)
+	results.preselect = *selectIndex
+	if err := initIgnores(search.basepath); err != nil {
+		fmt.Fprintln(os.Stderr, "nav:", err)
+		os.Exit(2)
+	}
+	initOneFilesystem(search.basepath)
+
+	if *jsonOut || *printAll {
+		paths := indexAll(search.basepath)
+		stats.WalkDone()
+		if *showStats {
+			stats.Print(os.Stderr, len(paths))
+		}
+		select {
+		case err := <-walkErrors:
+			fmt.Fprintln(os.Stderr, "nav:", err)
+			os.Exit(1)
+		default:
+		}
+		m := search.Matcher()
+		sortByScore(paths, m)
+		matches := matching(paths, m)
+		orderOutput(matches)
+		write := writeJSON
+		if !*jsonOut {
+			write = writeLines
+		}
+		if err := write(os.Stdout, matches); err != nil {
+			fmt.Fprintln(os.Stderr, "nav:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	st, err := loadState()
+	if err != nil {
+		log.Printf("loading state: %v", err)
+	}
+	search.history = st.project(search.basepath).History
+	if *resumeSel {
+		results.resume = st.project(search.basepath).Last
+	}
+	results.revealPath = *revealAt
+	results.pinned = map[string]bool{}
+	for _, path := range st.project(search.basepath).Pinned {
+		results.pinned[path] = true
+	}
+	results.scores = *showScores
+
+	if *watchTree && !readingList() {
+		w, err := newTreeWatcher()
+		if err != nil {
+			log.Printf("watch disabled: %v", err)
+		} else {
+			watcher = w
+		}
+	}
+
+	// a closed terminal or a killed parent must still restore the terminal,
+	// so these signals go through the event loop like any other exit
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGHUP)
+
+	// termbox talks to /dev/tty rather than stdin/stdout, so nav works in
+	// pipelines as long as there is a controlling terminal
+	if err := initTerminal(); err != nil {
+		fmt.Fprintf(os.Stderr, "nav: no terminal available (%v); use -json for headless output\n", err)
+		os.Exit(1)
 	}
-	// Kill program with CtrlC
-	termbox.SetInputMode(termbox.InputAlt | termbox.InputMouse)
 
 	eventCh := make(chan event)
 
 	go pollEvents(eventCh)
+	go runHeartbeat()
+	go func() {
+		for err := range walkErrors {
+			eventCh <- event{evType: EventError, err: err}
+		}
+	}()
+	go func() {
+		for range uniqueMatch {
+			eventCh <- event{evType: EventSelected}
+		}
+	}()
+	go func() {
+		sig := <-signals
+		eventCh <- event{evType: EventError, err: signalError{sig.(syscall.Signal)}}
+	}()
+
+	path, ok, err := run(eventCh)
 
-	result, err := run(eventCh)
+	shutdown()
+	closeTerminal()
+
+	if *showStats {
+		_, indexed, _, _ := results.Counts()
+		stats.Print(os.Stderr, indexed)
+	}
+	if err == errExecQuit {
+		return
+	}
+	if sig, ok := err.(signalError); ok {
+		// the conventional status for death by signal
+		os.Exit(128 + int(sig.sig))
+	}
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, "nav:", err)
+		os.Exit(1)
+	}
+
+	if !ok {
+		os.Stdout.WriteString(*cancelOut)
+		return
+	}
+	if revealSelection {
+		if err := revealPath(path); err != nil {
+			fmt.Fprintln(os.Stderr, "nav:", err)
+			os.Exit(1)
+		}
+		return
 	}
+	os.Stdout.WriteString(formatResult(path))
+}
+
+// signalError ends run when nav is sent SIGTERM or SIGHUP.
+type signalError struct {
+	sig syscall.Signal
+}
 
-	termbox.Close()
-	os.Stdout.WriteString(result)
+func (e signalError) Error() string {
+	return e.sig.String()
 }
 
 func pollEvents(eventCh chan<- event) {
 	var prev event
+	// the last click, for spotting a second one on the same row
+	var lastClick time.Time
+	var lastClickY int
 	for {
 		func() {
 			ev := termbox.PollEvent()
@@ -130,14 +422,30 @@ func pollEvents(eventCh chan<- event) {
 				eventCh <- event{evType: EventError, err: ev.Err}
 				return
 			}
+			if ev.Type == termbox.EventResize {
+				// whatever the terminal reflowed is garbage now
+				go repaint()
+				return
+			}
 
 			// Mouse events
 			if ev.Type == termbox.EventMouse {
+				// clicks above a -height view belong to no box
+				ev.MouseY -= viewTop()
+				if ev.MouseY < 0 {
+					return
+				}
 				var curr event
 				switch ev.Key {
 				case termbox.MouseRelease:
 					if prev.evType == EventMousePress {
 						curr = event{evType: EventMouseClick, mouseX: ev.MouseX, mouseY: ev.MouseY}
+						if time.Since(lastClick) <= *doubleClick && ev.MouseY == lastClickY {
+							curr.evType = EventMouseDoubleClick
+							lastClick = time.Time{}
+						} else {
+							lastClick, lastClickY = time.Now(), ev.MouseY
+						}
 					}
 				case termbox.MouseWheelDown:
 					curr = event{evType: EventMouseScrollDown, mouseX: ev.MouseX, mouseY: ev.MouseY}
@@ -161,66 +469,129 @@ func pollEvents(eventCh chan<- event) {
 
 			// Keyboard events
 			if ev.Type == termbox.EventKey {
-				switch ev.Key {
-				case termbox.KeyEnter:
-					eventCh <- event{evType: EventSelected}
-					return
-				case termbox.KeyEsc, termbox.KeyCtrlC:
-					eventCh <- event{evType: EventShutdown}
+				if b, ok := lookupBinding(ev); ok {
+					eventCh <- event{evType: b.ev}
 					return
-				case termbox.KeyArrowLeft, termbox.KeyCtrlB:
-					eventCh <- event{evType: EventMoveCursorBackwardOneRune}
-				case termbox.KeyArrowRight, termbox.KeyCtrlF:
-					eventCh <- event{evType: EventMoveCursorForwardOneRune}
-				case termbox.KeyBackspace, termbox.KeyBackspace2:
-					if ev.Mod == termbox.ModAlt {
-						eventCh <- event{evType: EventDeleteWordBackward}
-					} else {
-						eventCh <- event{evType: EventDeleteRuneBackward}
-					}
-				case termbox.KeyDelete, termbox.KeyCtrlD:
-					eventCh <- event{evType: EventDeleteRuneForward}
-				case termbox.KeySpace:
+				}
+				switch {
+				case ev.Key == termbox.KeySpace && ev.Mod == 0:
 					eventCh <- event{evType: EventInsertRune, ch: ' '}
-				case termbox.KeyArrowDown:
-					eventCh <- event{evType: EventMoveSelectionDownOne}
-				case termbox.KeyArrowUp:
-					eventCh <- event{evType: EventMoveSelectionUpOne}
-				default:
-					if ev.Ch != 0 {
-						if ev.Mod == termbox.ModAlt {
-							switch ev.Ch {
-							case 'b':
-								eventCh <- event{evType: EventMoveCursorBackwardOneWord}
-							case 'f':
-								eventCh <- event{evType: EventMoveCursorForwardOneWord}
-							}
-						} else {
-							eventCh <- event{evType: EventInsertRune, ch: ev.Ch}
-						}
-					}
+				case ev.Ch != 0 && ev.Mod == 0:
+					eventCh <- event{evType: EventInsertRune, ch: ev.Ch}
 				}
 			}
 		}()
 	}
 }
 
-func run(eventCh chan event) (string, error) {
+// revealSelection is set by run when the result should be opened in the file
+// manager rather than printed.
+var revealSelection bool
+
+// parentOf is the directory containing path, for Alt-Enter, which never
+// climbs above the basepath.
+func parentOf(path string) string {
+	if path == search.basepath {
+		return path
+	}
+	return filepath.Dir(path)
+}
+
+// run drives the picker until the user chooses a path (ok is true) or gives up.
+func run(eventCh chan event) (path string, ok bool, err error) {
 	go results.Init()
 
 	draw()
 	for ev := range eventCh {
+		// the help overlay swallows keys until it is dismissed
+		if help.Visible() {
+			switch ev.evType {
+			case EventMouseDrag, EventMousePress, EventMouseClick, EventMouseDoubleClick, EventMouseScrollDown, EventMouseScrollUp, EventError:
+			default:
+				help.Advance()
+				draw()
+				continue
+			}
+		}
+
+		// jump labels take the next keys, and any other key backs out
+		if results.Jumping() && ev.evType != EventError {
+			if ev.evType != EventInsertRune {
+				results.CancelJump()
+			} else if results.Jump(ev.ch) && !results.OnHeader() {
+				path, ok := results.Selection()
+				saveHistory(search.Value(), path, ok)
+				revealSelection = ok && *reveal
+				return path, ok, nil
+			}
+			draw()
+			continue
+		}
+
+		// any user input overrides the startup -select
+		results.CancelPreselect()
+		// only up/down keep browsing the query history
+		if ev.evType != EventMoveSelectionUpOne && ev.evType != EventMoveSelectionDownOne {
+			search.LeaveHistory()
+		}
+
+		// choosing a -group header expands or collapses it instead
+		if ev.evType == EventSelected && results.OnHeader() {
+			results.ToggleGroup()
+			draw()
+			continue
+		}
+
 		switch ev.evType {
-		case EventSelected:
-			return results.Selected(), nil
+		case EventSelected, EventReveal:
+			path, ok := results.Selection()
+			saveHistory(search.Value(), path, ok)
+			revealSelection = ok && (*reveal || ev.evType == EventReveal)
+			return path, ok, nil
+		case EventSelectParent:
+			path, ok := results.Selection()
+			saveHistory(search.Value(), path, ok)
+			if ok {
+				path = parentOf(path)
+			}
+			revealSelection = ok && *reveal
+			return path, ok, nil
 		case EventShutdown:
-			return ".", nil // TODO: os.Exit?
+			return "", false, nil // TODO: os.Exit?
 		case EventError:
-			return ".", ev.err
+			return "", false, ev.err
 		}
 
 		switch ev.evType {
+		case EventHelp:
+			help.Show()
+		case EventToggleGroup:
+			if *inline {
+				results.CycleSelection()
+				break
+			}
+			results.ToggleGroup()
+		case EventToggleAbsolute:
+			search.ToggleAbsolute()
+		case EventToggleDirsOnly:
+			results.ToggleDirsOnly()
+		case EventJump:
+			results.StartJump()
+		case EventToggleScores:
+			results.ToggleScores()
+		case EventTogglePin:
+			results.TogglePin()
+		case EventToggleInvert:
+			results.ToggleInvert()
+		case EventCycleScorer:
+			search.CycleScorer()
+		case EventToggleMatchBase:
+			search.ToggleMatchBase()
 		case EventInsertRune:
+			if ev.ch == '?' && search.Value() == "" {
+				help.Show()
+				break
+			}
 			search.InsertRune(ev.ch)
 		case EventMoveCursorBackwardOneRune:
 			search.MoveCursorOneRuneBackward()
@@ -236,10 +607,56 @@ func run(eventCh chan event) (string, error) {
 			search.DeleteRuneForward()
 		case EventDeleteWordBackward:
 			search.DeleteWordBackward()
+		case EventClearQuery:
+			search.Clear()
 		case EventMoveSelectionDownOne:
-			results.MoveSelectionDownOne()
+			if !search.HistoryDown() {
+				results.MoveSelectionDownOne()
+			}
 		case EventMoveSelectionUpOne:
-			results.MoveSelectionUpOne()
+			if !search.HistoryUp(results.AtTop()) {
+				results.MoveSelectionUpOne()
+			}
+		case EventScrollLeft:
+			results.ScrollLeft()
+		case EventScrollRight:
+			results.ScrollRight()
+		case EventRefresh:
+			go results.Refresh()
+		case EventForceRefresh:
+			listings.Clear()
+			go results.Refresh()
+		case EventCopyAbsolute:
+			if path, ok := results.Selection(); ok {
+				copySelection(path)
+			}
+		case EventCopyRelative:
+			if path, ok := results.Selection(); ok {
+				copySelection(search.relativePath(path))
+			}
+		case EventCopyBasename:
+			if path, ok := results.Selection(); ok {
+				copySelection(filepath.Base(path))
+			}
+		case EventShell:
+			if path, ok := results.Selection(); ok {
+				if err := openShell(path); err != nil {
+					return "", false, err
+				}
+			}
+		case EventActOnSelection:
+			if path, ok := results.Selection(); ok {
+				actOnSelection(path)
+			}
+		case EventExec:
+			if path, ok := results.Selection(); ok {
+				if err := execSelection(path); err != nil {
+					return "", false, err
+				}
+				if *execQuit && *execCmd != "" {
+					return "", false, errExecQuit
+				}
+			}
 		case EventMouseDrag, EventMousePress:
 			results.MousePress(ev.mouseY)
 		case EventMouseScrollDown:
@@ -247,338 +664,1721 @@ func run(eventCh chan event) (string, error) {
 		case EventMouseScrollUp:
 			results.MouseScrollUp()
 		case EventMouseClick:
+			// with -double-click, a single click only moves the selection
+			if *doubleClick == 0 {
+				results.MouseClick(ev.mouseX, ev.mouseY, eventCh)
+			}
+		case EventMouseDoubleClick:
 			results.MouseClick(ev.mouseX, ev.mouseY, eventCh)
 		}
 		draw()
 	}
 
-	return ".", nil
+	return "", false, nil
 }
 
 var drawMutex sync.Mutex
 
+// drawClosed stops drawing once termbox is about to close.
+var drawClosed bool
+
+// quit is closed on exit to stop any walk still in progress.
+var quit = make(chan struct{})
+
+// walkErrors carries the first failure from the walkers to run, which exits
+// with it.
+var walkErrors = make(chan error, 1)
+
+// reportError hands err to run without ever blocking a walker.
+func reportError(err error) {
+	select {
+	case walkErrors <- err:
+	default:
+	}
+}
+
+// shutdown cancels the walk, gives it a moment to wind down, and stops all
+// further drawing so termbox can be closed safely.
+func shutdown() {
+	close(quit)
+	select {
+	case <-results.initDone:
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	drawMutex.Lock()
+	drawClosed = true
+	drawMutex.Unlock()
+}
+
+// monochrome restricts all drawing to the default colors, differentiating
+// with attributes only. See https://no-color.org.
+var monochrome bool
+
+func setCell(x, y int, r rune, fg, bg termbox.Attribute) {
+	if monochrome {
+		const attrs = termbox.AttrBold | termbox.AttrUnderline | termbox.AttrReverse
+		fg, bg = fg&attrs, bg&attrs
+	}
+	if screen != nil {
+		screen.SetCell(x, y, r, fg, bg)
+		return
+	}
+	termbox.SetCell(x, y, r, fg, bg)
+}
+
+// setCursor shows the cursor at x, y within the view.
+func setCursor(x, y int) {
+	if screen != nil {
+		screen.SetCursor(x, y)
+		return
+	}
+	termbox.SetCursor(x, y)
+}
+
+func hideCursor() {
+	if screen != nil {
+		screen.SetCursor(-1, -1)
+		return
+	}
+	termbox.HideCursor()
+}
+
 func draw() {
 	go func() {
 		drawMutex.Lock()
 		defer drawMutex.Unlock()
 
+		if drawClosed {
+			return
+		}
+		render(false)
+	}()
+}
+
+// render draws every box, rewriting the whole view if full is set rather than
+// only what changed. drawMutex must be held.
+func render(full bool) {
+	if screen != nil {
+		screen.Clear()
+	} else {
 		termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	}
+	if !tooSmall() {
 		search.Draw()
 		results.Draw()
+		status.Draw()
+		help.Draw()
 		debug.Draw()
+	} else {
+		drawTooSmall()
+	}
+
+	switch {
+	case screen != nil:
+		// always rewritten in full
+		screen.Flush()
+	case full:
+		termbox.Sync()
+	default:
 		termbox.Flush()
-	}()
+	}
 }
 
 type resultsBox struct {
 	matches        []string
+	rowLabels      []string // labels for -tree and -group rows
+	rowHeaders     []bool   // which -group rows are directory headers
 	selected       int
+	displayOffsetX int
 	displayOffsetY int
+	// inlineStart is the first match drawn on the -inline row
+	inlineStart int
+	// scoring is the query whose matches are being finished in the background
+	// after Recalculate ran out of time, or nil
+	scoring *matchKey
+	// jumping shows jump labels until a label is typed; jumpTyped is what has
+	// been typed of one so far
+	jumping   bool
+	jumpTyped string
+
+	// preselect is the index requested by -select. It is reapplied on every
+	// Recalculate until the user interacts, since the walk streams results in.
+	preselect int
+	// resume is the last selection, with -resume-selection, reapplied in the
+	// same way until the user interacts.
+	resume string
+	// revealPath is -reveal-path, reapplied in the same way.
+	revealPath string
 
 	mu        sync.Mutex
 	filepaths []string
+	// basenames holds the base name of each of filepaths, for -match-basename
+	// to score without finding it again for every query
+	basenames []string
+	truncated map[string]bool
+	collapsed map[string]bool
+	cache     matchCache
+	walkDone  bool
+	initDone  chan struct{}
+
+	// generation counts changes to filepaths, keying the match cache
+	generation int
+
+	// shortQuery is set while the query is shorter than -min-query
+	shortQuery bool
+
+	// dirsOnly hides entries that aren't directories themselves: -files
+	// files and -symlinks links
+	dirsOnly bool
+	// scores shows each row's score, as -show-scores does at startup
+	scores bool
+	// pinned paths are listed before the rest of the matches
+	pinned map[string]bool
+	// inverted lists the paths that don't match the query instead
+	inverted bool
+	// contexts are the parents shown after -disambiguate base names, as of
+	// the last draw
+	contexts map[string]string
+}
+
+// maxReaders bounds how many directories are read at once.
+const maxReaders = 32
+
+// walk indexes every directory beneath root, sending batches of paths on
+// filepaths and closing it once the whole tree has been read. The tree is read
+// a level at a time, so shallow directories, usually the interesting ones,
+// are listed before anything deeper.
+func walk(root string, filepaths chan<- []string) {
+	defer close(filepaths)
+
+	readers := make(chan struct{}, maxReaders)
+	level := []string{root}
+	for len(level) > 0 {
+		var (
+			wg   sync.WaitGroup
+			mu   sync.Mutex
+			next []string
+		)
+		for _, dirname := range level {
+			wg.Add(1)
+			readers <- struct{}{}
+			go func(dirname string) {
+				defer wg.Done()
+				subdirs := readirs(dirname, filepaths)
+				<-readers
+
+				mu.Lock()
+				next = append(next, subdirs...)
+				mu.Unlock()
+			}(dirname)
+		}
+		wg.Wait()
+		level = next
+	}
+}
+
+// indexAll walks root to completion and returns every path found, root first.
+func indexAll(root string) []string {
+	var paths []string
+	dirs := make(chan []string)
+	if readingList() {
+		go readList(dirs)
+	} else {
+		if !*noSelf {
+			paths = append(paths, root)
+		}
+		go walk(root, dirs)
+	}
+	for filepaths := range dirs {
+		paths = append(paths, capIndex(len(paths), filepaths)...)
+	}
+	return paths
+}
+
+var indexCapped sync.Once
+
+// capIndex returns as many of more as fit in an index already holding have
+// paths under -max-index, logging the first time any are dropped.
+func capIndex(have int, more []string) []string {
+	if *maxIndex <= 0 || have+len(more) <= *maxIndex {
+		return more
+	}
+	indexCapped.Do(func() {
+		log.Printf("index truncated at -max-index %d paths", *maxIndex)
+	})
+	if have >= *maxIndex {
+		return nil
+	}
+	return more[:*maxIndex-have]
 }
 
-func readirs(dirname string, filepaths chan<- []string) {
-	infos, err := ioutil.ReadDir(dirname)
+// readirs sends the subdirectories of dirname, and with -files its regular
+// files, on filepaths as one batch and returns those the walk should descend
+// into.
+func readirs(dirname string, filepaths chan<- []string) (descend []string) {
+	select {
+	case <-quit:
+		return nil
+	default:
+	}
+
+	dirname = filepath.Clean(dirname)
+	infos, err := listings.ReadDir(dirname)
 	if err != nil {
-		return
+		return nil
 	}
+	stats.CountDir()
 	var dirpaths []string
 	for _, info := range infos {
+		if *maxPerDir > 0 && len(dirpaths) >= *maxPerDir {
+			log.Printf("%s: truncated after %d entries", dirname, *maxPerDir)
+			results.MarkTruncated(dirname)
+			break
+		}
+		if !info.IsDir() {
+			stats.CountFile()
+		}
 		filename, err := filepath.Abs(filepath.Join(dirname, info.Name()))
 		if err != nil {
-			panic(err)
+			reportError(err)
+			return nil
+		}
+		filename = filepath.Clean(filename)
+		if *listSymlinks && info.Mode()&os.ModeSymlink != 0 && !ignored(filename) {
+			if target, ok := readLink(filename); ok {
+				linkTargets.Set(filename, target)
+				dirpaths = append(dirpaths, filename)
+			}
+			continue
+		}
+		if *includeFiles && info.Mode().IsRegular() && !ignoredFile(filename) {
+			if keepMtimes() {
+				mtimes.Set(filename, info.ModTime())
+			}
+			indexedFiles.Add(filename)
+			dirpaths = append(dirpaths, filename)
+			continue
+		}
+		if info.IsDir() && !ignored(filename) {
+			if keepMtimes() {
+				mtimes.Set(filename, info.ModTime())
+			}
+			dirpaths = append(dirpaths, filename)
+			if crossesFilesystem(info) {
+				log.Printf("%s: not descending into another filesystem", filename)
+				continue
+			}
+			descend = append(descend, filename)
+		}
+	}
+	if len(dirpaths) > 0 {
+		select {
+		case filepaths <- dirpaths:
+			stats.FirstResult()
+		case <-quit:
+			return nil
+		}
+	}
+	return descend
+}
+
+func (b *resultsBox) Init() {
+	dirs := make(chan []string)
+
+	if readingList() {
+		go readList(dirs)
+	} else {
+		if !*noSelf {
+			b.AppendFilepaths([]string{search.basepath})
+		}
+		if watcher != nil {
+			watcher.Add([]string{search.basepath})
+		}
+		go walk(search.basepath, dirs)
+	}
+
+	for filepaths := range dirs {
+		b.AppendFilepaths(filepaths)
+		if watcher != nil {
+			watcher.Add(filepaths)
+		}
+		draw()
+	}
+
+	stats.WalkDone()
+	b.mu.Lock()
+	b.walkDone = true
+	if _, ok := b.findReveal(); b.revealPath != "" && !ok {
+		log.Printf("-reveal-path %s: not found", b.revealPath)
+		b.revealPath = ""
+	}
+	b.mu.Unlock()
+	close(b.initDone)
+	// settle the ranking over the complete index in one last pass
+	b.Recalculate()
+	draw()
+}
+
+func (b *resultsBox) Draw() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if *disambiguate {
+		b.contexts = b.visibleContexts()
+	}
+	b.clampOffsetX()
+	gutter := b.gutterWidth()
+	top := viewLayout().results
+	w, _ := viewSize()
+
+	if len(b.matches) == 0 && (b.walkDone || b.shortQuery) {
+		msg := "no matches"
+		if b.shortQuery {
+			msg = fmt.Sprintf("keep typing: results appear after %d characters", *minQuery)
+		} else if len(b.filepaths) == 0 && readingList() {
+			msg = "no input"
+		} else if len(b.filepaths) == 0 {
+			msg = "no subdirectories"
+		}
+		drawText(gutter, top, w, msg, termbox.AttrBold, termbox.ColorDefault)
+		return
+	}
+
+	if *inline {
+		b.drawInline(top, w)
+		return
+	}
+
+	rows := resultRows()
+	right := b.rightColumns()
+	for i := b.displayOffsetY; i < len(b.matches); i++ {
+		y := i - b.displayOffsetY
+		// termbox clips to its own size, which -size may exceed
+		if y >= rows {
+			break
+		}
+		fg, bg := termbox.ColorDefault, termbox.ColorDefault
+		if y+b.displayOffsetY == b.selected {
+			if !*compact {
+				setCell(0, y+top, '►', fg, bg)
+			}
+			fg = selectedStyle()
+		}
+		if b.isPinned(b.matches[i]) && !b.isHeader(i) && !*compact {
+			setCell(1, y+top, '*', termbox.ColorDefault, bg)
+		}
+		if *lineNumbers {
+			num := strconv.Itoa(i + 1)
+			for x, r := range num {
+				setCell(gutter-1-len(num)+x, y+top, r, termbox.ColorDefault, bg)
+			}
+		}
+		// displayOffsetX counts columns, like everything else on screen
+		display := dropColumns([]rune(b.label(i)), b.displayOffsetX)
+		drawText(gutter, y+top, w-right, string(display), fg, bg)
+	}
+	b.drawScores()
+	b.drawJumpLabels()
+	b.drawMinimap()
+}
+
+// selectedStyle is the -select-style attribute for the selected row. Without
+// the marker, -compact makes the default stand out more.
+func selectedStyle() termbox.Attribute {
+	if *compact && *selectStyle == "default" {
+		return termbox.AttrBold | termbox.AttrReverse
+	}
+	switch *selectStyle {
+	case "reverse":
+		return termbox.AttrReverse
+	case "both":
+		return termbox.AttrBold | termbox.AttrUnderline | termbox.AttrReverse
+	}
+	return termbox.AttrBold | termbox.AttrUnderline
+}
+
+// gutterWidth is the number of columns left of the path text, reserved for
+// whichever per-row indicators are enabled.
+func (b *resultsBox) gutterWidth() int {
+	width := 2 // selection marker
+	if *compact {
+		width = 0
+	}
+	if *lineNumbers {
+		_, end := b.visibleRange()
+		width += len(strconv.Itoa(end)) + 1
+	}
+	return width
+}
+
+// label is the text drawn for the ith match: its path and then any notes
+// about it, which -align-notes lines up in a column across the visible rows.
+func (b *resultsBox) label(i int) string {
+	label, notes := b.pathLabel(i), b.notes(i)
+	if notes == "" {
+		return label
+	}
+	if *alignNotes {
+		if pad := b.notesColumn() - textWidth([]rune(label)); pad > 0 {
+			label += strings.Repeat(" ", pad)
+		}
+	}
+	return label + " " + notes
+}
+
+// pathLabel is the path part of the ith label.
+func (b *resultsBox) pathLabel(i int) string {
+	var label string
+	if b.rowLabels != nil {
+		label = b.rowLabels[i]
+	} else if *disambiguate {
+		label = b.shortLabel(b.matches[i])
+	} else {
+		label = search.displayPath(b.matches[i])
+	}
+	if *foldDisplay {
+		// rune for rune, so columns still line up with the real path
+		label = strings.Map(unicode.ToLower, label)
+	}
+	return label
+}
+
+// notes annotates the ith match with where it links to and whether it was
+// truncated.
+func (b *resultsBox) notes(i int) string {
+	var notes []string
+	if target, ok := linkTargets.Get(b.matches[i]); ok {
+		notes = append(notes, "-> "+target)
+	}
+	if b.truncated[b.matches[i]] {
+		notes = append(notes, "(truncated)")
+	}
+	return strings.Join(notes, " ")
+}
+
+// notesColumn is the width of the longest visible path that has notes.
+func (b *resultsBox) notesColumn() int {
+	var width int
+	start, end := b.visibleRange()
+	for i := start; i < end; i++ {
+		if b.notes(i) == "" {
+			continue
+		}
+		if n := textWidth([]rune(b.pathLabel(i))); n > width {
+			width = n
+		}
+	}
+	return width
+}
+
+// MarkTruncated flags a directory whose listing was cut short by -max-per-dir.
+func (b *resultsBox) MarkTruncated(path string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.truncated == nil {
+		b.truncated = map[string]bool{}
+	}
+	b.truncated[path] = true
+}
+
+// visibleRange returns the indices of the matches currently on screen.
+func (b *resultsBox) visibleRange() (start, end int) {
+	start, end = b.displayOffsetY, b.displayOffsetY+resultRows()
+	if end > len(b.matches) {
+		end = len(b.matches)
+	}
+	if start > end {
+		start = end
+	}
+	return start, end
+}
+
+// clampOffsetX keeps the horizontal scroll within the longest visible row.
+func (b *resultsBox) clampOffsetX() {
+	w, _ := viewSize()
+	var longest int
+	start, end := b.visibleRange()
+	for i := start; i < end; i++ {
+		if n := textWidth([]rune(b.label(i))); n > longest {
+			longest = n
+		}
+	}
+	max := longest - (w - b.gutterWidth() - b.rightColumns())
+	if max < 0 {
+		max = 0
+	}
+	if b.displayOffsetX > max {
+		b.displayOffsetX = max
+	}
+	if b.displayOffsetX < 0 {
+		b.displayOffsetX = 0
+	}
+}
+
+func (b *resultsBox) ScrollLeft() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.displayOffsetX--
+	b.clampOffsetX()
+}
+
+func (b *resultsBox) ScrollRight() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.displayOffsetX++
+	b.clampOffsetX()
+}
+
+// scrollMargin is the -scrolloff context, limited to what fits on screen.
+func (b *resultsBox) scrollMargin() int {
+	margin := *scrollOff
+	if max := (resultRows() - 1) / 2; margin > max {
+		margin = max
+	}
+	if margin < 0 {
+		margin = 0
+	}
+	return margin
+}
+
+func (b *resultsBox) focusTop() {
+	b.displayOffsetY = b.selected - b.scrollMargin()
+	if b.displayOffsetY < 0 {
+		b.displayOffsetY = 0
+	}
+}
+
+func (b *resultsBox) focusBottom() {
+	b.displayOffsetY = b.selected - resultRows() + 1 + b.scrollMargin()
+	// the margin shrinks at the end of the list
+	if max := len(b.matches) - resultRows(); b.displayOffsetY > max {
+		b.displayOffsetY = max
+	}
+	if b.displayOffsetY < 0 {
+		b.displayOffsetY = 0
+	}
+}
+
+// clampView pulls the view back over the end of the list once it shrinks, so
+// matches above the old offset aren't left off screen.
+func (b *resultsBox) clampView() {
+	if max := len(b.matches) - resultRows(); b.displayOffsetY > max {
+		b.displayOffsetY = max
+	}
+	if b.displayOffsetY < 0 {
+		b.displayOffsetY = 0
+	}
+	if b.selected >= 0 {
+		b.scrollToSelected()
+	}
+}
+
+func (b *resultsBox) scrollToSelected() {
+	margin := b.scrollMargin()
+
+	// selected is off screen up above
+	if b.selected-margin < b.displayOffsetY {
+		b.focusTop()
+	}
+
+	// selected is off screen down below
+	if b.displayOffsetY+resultRows()-1 < b.selected+margin {
+		b.focusBottom()
+	}
+}
+
+func (b *resultsBox) CancelPreselect() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.preselect = -1
+	b.resume = ""
+	b.revealPath = ""
+}
+
+func (b *resultsBox) applyPreselect() {
+	if i, ok := b.findReveal(); ok {
+		b.selected = i
+		b.scrollToSelected()
+		return
+	}
+	if b.resume != "" {
+		for i, match := range b.matches {
+			if match == b.resume && !b.isHeader(i) {
+				b.selected = i
+				b.scrollToSelected()
+				return
+			}
+		}
+	}
+	if b.preselect < 0 || len(b.matches) == 0 {
+		return
+	}
+	b.selected = b.preselect
+	if b.selected >= len(b.matches) {
+		b.selected = len(b.matches) - 1
+	}
+	b.scrollToSelected()
+}
+
+// findReveal finds -reveal-path among the matches: the path itself, relative
+// to the basepath unless absolute, or failing that the best ranked match
+// ending with it at a segment boundary.
+func (b *resultsBox) findReveal() (int, bool) {
+	if b.revealPath == "" {
+		return 0, false
+	}
+	exact := b.revealPath
+	if !filepath.IsAbs(exact) {
+		exact = filepath.Join(search.basepath, exact)
+	}
+	exact = filepath.Clean(exact)
+	suffix := string(filepath.Separator) + strings.Trim(filepath.Clean(b.revealPath), string(filepath.Separator))
+	found := -1
+	for i, match := range b.matches {
+		if b.isHeader(i) {
+			continue
+		}
+		if match == exact {
+			return i, true
+		}
+		if found < 0 && strings.HasSuffix(match, suffix) {
+			found = i
+		}
+	}
+	return found, found >= 0
+}
+
+func (b *resultsBox) MousePress(y int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	y -= viewLayout().results
+	if y < 0 {
+		go b.MouseScrollUp()
+		return
+	}
+
+	if y >= len(b.matches) {
+		go b.MouseScrollDown()
+		return
+	}
+
+	b.selected = y + b.displayOffsetY
+}
+
+func (b *resultsBox) MouseClick(x, y int, eventCh chan<- event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.selected < 0 || b.selected >= len(b.matches) {
+		return
+	}
+	if y+b.displayOffsetY-viewLayout().results != b.selected {
+		return
+	}
+	x -= b.gutterWidth()
+	if x < 0 || x+b.displayOffsetX >= textWidth([]rune(b.label(b.selected))) {
+		return
+	}
+	go func() {
+		eventCh <- event{evType: EventSelected}
+	}()
+}
+
+func (b *resultsBox) MouseScrollDown() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if resultRows() > len(b.matches)-b.displayOffsetY {
+		return
+	}
+
+	b.displayOffsetY++
+}
+
+func (b *resultsBox) MouseScrollUp() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.displayOffsetY <= 0 {
+		return
+	}
+
+	b.displayOffsetY--
+}
+
+func (b *resultsBox) MoveSelectionDownOne() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.selected < len(b.matches)-1 {
+		b.selected++
+	}
+
+	b.scrollToSelected()
+}
+
+func (b *resultsBox) MoveSelectionUpOne() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.selected > 0 {
+		b.selected--
+	}
+
+	b.scrollToSelected()
+}
+
+func (b *resultsBox) AppendFilepaths(filepaths []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// sort a fresh slice: appending in place could reorder the array that
+	// b.matches and any background scoring still share with b.filepaths
+	more := capIndex(len(b.filepaths), filepaths)
+	all := make([]string, 0, len(b.filepaths)+len(more))
+	all = append(all, b.filepaths...)
+	all = append(all, more...)
+	sortByScore(all, search.Matcher())
+	b.setIndex(all)
+
+	go b.Recalculate()
+}
+
+// setIndex replaces the index with paths. b.mu must be held.
+func (b *resultsBox) setIndex(paths []string) {
+	b.filepaths = paths
+	b.basenames = make([]string, len(paths))
+	for i, path := range paths {
+		b.basenames[i] = filepath.Base(path)
+	}
+	b.generation++
+}
+
+// Refresh re-walks the tree and replaces the index once the walk completes.
+// Directories unchanged since the previous refresh are served from the
+// listings cache.
+func (b *resultsBox) Refresh() {
+	b.mu.Lock()
+	walking := !b.walkDone
+	b.mu.Unlock()
+	if walking {
+		search.Notify("still indexing")
+		return
+	}
+	// stdin can't be read again
+	if *fromStdin {
+		search.Notify("nothing to refresh")
+		return
+	}
+
+	search.Notify("refreshing")
+	listings.Enable()
+	paths := indexAll(search.basepath)
+
+	b.mu.Lock()
+	sortByScore(paths, search.Matcher())
+	b.setIndex(paths)
+	b.mu.Unlock()
+
+	b.Recalculate()
+	search.Notify("refreshed")
+	draw()
+}
+
+// RemoveFilepaths drops path and everything beneath it from the index.
+func (b *resultsBox) RemoveFilepaths(path string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	prefix := path + string(filepath.Separator)
+	kept := make([]string, 0, len(b.filepaths))
+	for _, p := range b.filepaths {
+		if p != path && !strings.HasPrefix(p, prefix) {
+			kept = append(kept, p)
+		}
+	}
+	b.setIndex(kept)
+
+	go b.Recalculate()
+}
+
+// sortByScore orders paths best match first, breaking ties by length and
+// then lexically. With -sort=shortest, length comes first and the score only
+// breaks ties; every path shares the basepath prefix, so comparing full
+// lengths orders them as their displayed lengths would. -recent ignores the
+// score altogether.
+func sortByScore(paths []string, m matcher) {
+	if *recent {
+		sortByMtime(paths)
+		return
+	}
+	// an empty query scores everything the same, so skip straight to the
+	// tiebreaks
+	if len(m.value) == 0 {
+		sortByLength(paths)
+		return
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if *sortMode == "shortest" && len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) < len(paths[j])
+		}
+		si := m.Score(paths[i])
+		sj := m.Score(paths[j])
+		if si == sj {
+			if len(paths[i]) == len(paths[j]) {
+				return paths[i] < paths[j]
+			}
+			return len(paths[i]) < len(paths[j])
+		}
+		return si > sj
+	})
+}
+
+// sortByLength sorts paths shortest first, then lexically.
+func sortByLength(paths []string) {
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) == len(paths[j]) {
+			return paths[i] < paths[j]
+		}
+		return len(paths[i]) < len(paths[j])
+	})
+}
+
+// matching returns the paths that match m, in order.
+func matching(paths []string, m matcher) []string {
+	matches, _ := scoreUntil(m, nil, paths, nil, func() bool { return false })
+	return matches
+}
+
+// cacheKey identifies the current query against the current index. b.mu must
+// be held.
+func (b *resultsBox) cacheKey() matchKey {
+	return search.Matcher().key(b.generation)
+}
+
+func (b *resultsBox) Recalculate() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// follow the selected path, not its index, as batches reorder the list
+	var prev string
+	prevHeader := b.isHeader(b.selected)
+	if b.selected >= 0 && b.selected < len(b.matches) {
+		prev = b.matches[b.selected]
+	}
+
+	m := search.Matcher()
+	key := m.key(b.generation)
+	b.shortQuery = len(m.value) < *minQuery
+	if b.shortQuery {
+		// skip the scoring entirely until the query is long enough
+		b.matches = nil
+	} else if entry, ok := b.cache.Get(key); ok {
+		b.matches = entry.matches
+	} else {
+		deadline := time.Now().Add(recalcBudget)
+		matches, n := scoreUntil(m, nil, b.filepaths, b.basenames, func() bool {
+			return time.Now().After(deadline)
+		})
+		if n < len(b.filepaths) {
+			// show the best so far; the rest arrive with a later Recalculate
+			b.matches = matches
+			b.finishMatching(m, key, matches, b.filepaths[n:], b.basenames[n:])
+		} else {
+			b.matches = b.cache.Put(key, matches).matches
+		}
+	}
+	if b.inverted && !b.shortQuery {
+		b.matches = b.nonMatching(m)
+	}
+	if b.dirsOnly {
+		b.matches = onlyDirs(b.matches)
+	}
+	b.matches = b.pinnedFirst(b.matches)
+	b.rowLabels, b.rowHeaders = nil, nil
+	if *treeView {
+		b.matches, b.rowLabels = buildTree(search.basepath, b.matches, !*noSelf)
+	} else if *groupView {
+		b.matches, b.rowLabels, b.rowHeaders = buildGroups(b.matches, b.collapsed)
+	}
+	if prev != "" {
+		for i, match := range b.matches {
+			if match == prev && b.isHeader(i) == prevHeader {
+				if i != b.selected {
+					b.selected = i
+					b.scrollToSelected()
+				}
+				break
+			}
+		}
+	}
+	if b.selected >= len(b.matches) {
+		b.selected = len(b.matches) - 1
+	}
+	b.clampView()
+	b.applyPreselect()
+	b.checkUnique(m)
+}
+
+// onlyDirs returns the paths that are neither -files files nor symlinks,
+// leaving paths untouched since it may be cached.
+func onlyDirs(paths []string) []string {
+	dirs := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if _, ok := linkTargets.Get(path); !ok && !indexedFiles.Has(path) {
+			dirs = append(dirs, path)
+		}
+	}
+	return dirs
+}
+
+func (b *resultsBox) DirsOnly() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.dirsOnly
+}
+
+// ToggleDirsOnly switches between every entry and directories alone.
+func (b *resultsBox) ToggleDirsOnly() {
+	b.mu.Lock()
+	b.dirsOnly = !b.dirsOnly
+	b.mu.Unlock()
+
+	b.Recalculate()
+}
+
+// buildTree orders paths depth-first beneath root, adding any ancestors needed
+// to connect them, and returns the rows alongside their indented labels.
+func buildTree(root string, paths []string, includeRoot bool) (rows, labels []string) {
+	children := map[string][]string{}
+	seen := map[string]bool{root: true}
+	for _, path := range paths {
+		for !seen[path] {
+			seen[path] = true
+			parent := filepath.Dir(path)
+			if parent == path {
+				break
+			}
+			children[parent] = append(children[parent], path)
+			path = parent
+		}
+	}
+
+	var descend func(dir, prefix string)
+	descend = func(dir, prefix string) {
+		kids := children[dir]
+		sort.Strings(kids)
+		for i, kid := range kids {
+			branch, indent := "├── ", "│   "
+			if i == len(kids)-1 {
+				branch, indent = "└── ", "    "
+			}
+			rows = append(rows, kid)
+			labels = append(labels, prefix+branch+filepath.Base(kid))
+			descend(kid, prefix+indent)
+		}
+	}
+	if includeRoot {
+		rows = append(rows, root)
+		labels = append(labels, ".")
+	}
+	descend(root, "")
+	return rows, labels
+}
+
+// buildGroups clusters ranked paths under a header row for their parent
+// directory, ordering groups by their best member and omitting the members of
+// collapsed groups.
+func buildGroups(paths []string, collapsed map[string]bool) (rows, labels []string, headers []bool) {
+	var dirs []string
+	members := map[string][]string{}
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if _, ok := members[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		members[dir] = append(members[dir], path)
+	}
+
+	for _, dir := range dirs {
+		marker := "▾ "
+		if collapsed[dir] {
+			marker = "▸ "
+		}
+		rows = append(rows, dir)
+		labels = append(labels, fmt.Sprintf("%s%s (%d)", marker, search.displayPath(dir), len(members[dir])))
+		headers = append(headers, true)
+		if collapsed[dir] {
+			continue
+		}
+		for _, path := range members[dir] {
+			rows = append(rows, path)
+			labels = append(labels, "  "+filepath.Base(path))
+			headers = append(headers, false)
+		}
+	}
+	return rows, labels, headers
+}
+
+func (b *resultsBox) isHeader(i int) bool {
+	return i >= 0 && i < len(b.rowHeaders) && b.rowHeaders[i]
+}
+
+// OnHeader reports whether a -group header is highlighted.
+func (b *resultsBox) OnHeader() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.isHeader(b.selected)
+}
+
+// ToggleGroup collapses or expands the -group group holding the selection,
+// leaving the selection on its header.
+func (b *resultsBox) ToggleGroup() {
+	b.mu.Lock()
+	if !*groupView || b.selected < 0 || b.selected >= len(b.matches) {
+		b.mu.Unlock()
+		return
+	}
+	dir := b.matches[b.selected]
+	if !b.isHeader(b.selected) {
+		dir = filepath.Dir(dir)
+		for b.selected > 0 && !b.isHeader(b.selected) {
+			b.selected--
+		}
+	}
+	if b.collapsed == nil {
+		b.collapsed = map[string]bool{}
+	}
+	b.collapsed[dir] = !b.collapsed[dir]
+	b.mu.Unlock()
+
+	b.Recalculate()
+}
+
+func (b *resultsBox) SelectBestMatch() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// an explicit -select, -resume-selection or -reveal-path wins on startup
+	if b.preselect >= 0 || b.resume != "" || b.revealPath != "" {
+		return
+	}
+	// -recent lists the newest match first, whatever its score
+	if *recent {
+		for i := range b.matches {
+			if !b.isHeader(i) {
+				b.selected = i
+				b.scrollToSelected()
+				return
+			}
+		}
+		return
+	}
+
+	m := search.Matcher()
+	entry, cached := b.cache.Get(m.key(b.generation))
+	if cached && entry.best != "" {
+		for i, match := range b.matches {
+			if match == entry.best && !b.isHeader(i) {
+				b.selected = i
+				return
+			}
+		}
+	}
+
+	var bestScore float32
+	for i, match := range b.matches {
+		if b.isHeader(i) {
+			continue
 		}
-		if info.IsDir() {
-			dirpaths = append(dirpaths, filename)
-			go readirs(filename, filepaths)
+		score := m.Score(match)
+		if score > bestScore {
+			bestScore = score
+			b.selected = i
 		}
 	}
-	if len(dirpaths) > 0 {
-		filepaths <- dirpaths
+	if cached && bestScore > 0 {
+		entry.best = b.matches[b.selected]
 	}
 }
 
-func (b *resultsBox) Init() {
-	b.AppendFilepaths([]string{search.basepath})
-
-	dirs := make(chan []string)
+func (b *resultsBox) AtTop() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	go readirs(search.basepath, dirs)
+	return b.selected <= 0
+}
 
-	for filepaths := range dirs {
-		b.AppendFilepaths(filepaths)
-		draw()
+func (b *resultsBox) Selected() string {
+	if path, ok := b.Selection(); ok {
+		return path
 	}
+	return *cancelOut
 }
 
-func (b *resultsBox) Draw() {
+// Selection returns the highlighted path, if any.
+func (b *resultsBox) Selection() (string, bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	for i := b.displayOffsetY; i < len(b.matches); i++ {
-		y := i - b.displayOffsetY
-		path := b.matches[i]
-		fg, bg := termbox.ColorDefault, termbox.ColorDefault
-		if y+b.displayOffsetY == b.selected {
-			termbox.SetCell(0, y+3, '►', fg, bg)
-			fg = termbox.AttrBold | termbox.AttrUnderline
-		}
-		for x, r := range search.displayPath(path) {
-			termbox.SetCell(x+2, y+3, r, fg, bg)
-		}
+	// headers aren't results in their own right
+	if b.selected < 0 || len(b.matches) == 0 || b.isHeader(b.selected) {
+		return "", false
 	}
+	return b.matches[b.selected], true
 }
 
-func (b *resultsBox) focusTop() {
-	b.displayOffsetY = b.selected
+func copySelection(text string) {
+	if err := copyToClipboard(text); err != nil {
+		log.Printf("copying to clipboard: %v", err)
+		search.Notify("copy failed")
+		return
+	}
+	search.Notify("copied")
 }
 
-func (b *resultsBox) focusBottom() {
-	_, h := termbox.Size()
-	b.displayOffsetY = b.selected - (h - 3) + 1
+// defaultDelims separate words in the query for both motion and deletion.
+const defaultDelims = "\\/ .\t,-|"
+
+var (
+	motionDelims = flag.String("motion-delims", defaultDelims, "characters Alt-B and Alt-F stop at as word boundaries (leave out / to move over whole paths)")
+	deleteDelims = flag.String("delete-delims", defaultDelims, "characters Alt-Backspace stops at as word boundaries")
+)
+
+// delims returns a word-boundary test for the characters in set, and its
+// negation.
+func delims(set string) (delim, word func(rune) bool) {
+	delim = func(r rune) bool {
+		return strings.ContainsRune(set, r)
+	}
+	word = func(r rune) bool {
+		return !delim(r)
+	}
+	return delim, word
 }
 
-func (b *resultsBox) MousePress(y int) {
+type searchBox struct {
+	basepath       string
+	cursorOffsetX  int
+	cursorOffsetY  int
+	displayOffsetX int
+	value          []rune
+
+	// history holds previous queries for basepath, oldest first. historyIndex
+	// is the entry currently recalled into value, or -1 when not browsing.
+	history      []string
+	historyIndex int
+
+	// notice is a brief message shown in the top border
+	notice      string
+	noticeTimer *time.Timer
+
+	// absolute shows results as full paths rather than relative ones
+	absolute bool
+	// scorer indexes scorers, starting at -scorer
+	scorer int
+	// matchBase matches only the last segment of each path
+	matchBase bool
+
+	mu sync.Mutex
+}
+
+func (b *searchBox) Draw() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if y-3 < 0 {
-		go b.MouseScrollUp()
-		return
+	label := b.basepath + string(filepath.Separator)
+	w, _ := viewSize()
+	setCell(0, 0, '┌', termbox.ColorDefault, termbox.ColorDefault)
+	setCell(0, 1, '│', termbox.ColorDefault, termbox.ColorDefault)
+	setCell(0, 2, '└', termbox.ColorDefault, termbox.ColorDefault)
+	for i := 1; i < w-1; i++ {
+		setCell(i, 0, '─', termbox.ColorDefault, termbox.ColorDefault)
+		setCell(i, 2, '─', termbox.ColorDefault, termbox.ColorDefault)
 	}
+	setCell(w-1, 0, '┐', termbox.ColorDefault, termbox.ColorDefault)
+	setCell(w-1, 1, '│', termbox.ColorDefault, termbox.ColorDefault)
+	setCell(w-1, 2, '┘', termbox.ColorDefault, termbox.ColorDefault)
 
-	if y-3 >= len(b.matches) {
-		go b.MouseScrollDown()
-		return
+	if b.notice != "" {
+		drawText(2, 0, w-1, " "+b.notice+" ", termbox.AttrBold, termbox.ColorDefault)
 	}
 
-	b.selected = y + b.displayOffsetY - 3
+	start := drawText(1, 1, w-1, label, termbox.AttrBold, termbox.ColorDefault)
+
+	// scroll the query so the cursor stays between the label and the border,
+	// measuring in columns since runes may be wide or take no space at all
+	avail := w - 1 - start
+	if avail < 1 {
+		avail = 1
+	}
+	if b.displayOffsetX > len(b.value) {
+		b.displayOffsetX = len(b.value)
+	}
+	if b.cursorOffsetX < b.displayOffsetX {
+		b.displayOffsetX = b.cursorOffsetX
+	}
+	for b.displayOffsetX < b.cursorOffsetX && textWidth(b.value[b.displayOffsetX:b.cursorOffsetX]) >= avail {
+		b.displayOffsetX++
+	}
+	drawText(start, 1, w-1, string(b.value[b.displayOffsetX:]), termbox.ColorDefault, termbox.ColorDefault)
+	if len(b.value) == 0 {
+		// termbox has no dim attribute, so the hint is plain like the status line
+		drawText(start, 1, w-1, *placeholder, termbox.ColorDefault, termbox.ColorDefault)
+	}
+
+	cursor := start + textWidth(b.value[b.displayOffsetX:b.cursorOffsetX])
+	setCursor(cursor, b.cursorOffsetY+1)
 }
 
-func (b *resultsBox) MouseClick(x, y int, eventCh chan<- event) {
+// Notify shows msg in the top border for a couple of seconds.
+func (b *searchBox) Notify(msg string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if y+b.displayOffsetY-3 != b.selected {
-		return
-	}
-	if x-2 < 0 || x-2 >= len([]rune(search.displayPath(b.matches[b.selected]))) {
-		return
+	b.notice = msg
+	if b.noticeTimer != nil {
+		b.noticeTimer.Stop()
 	}
-	go func() {
-		eventCh <- event{evType: EventSelected}
-	}()
+	b.noticeTimer = time.AfterFunc(2*time.Second, func() {
+		b.mu.Lock()
+		b.notice = ""
+		b.mu.Unlock()
+		draw()
+	})
 }
 
-func (b *resultsBox) MouseScrollDown() {
+// matcher is a snapshot of the query and how it matches. Sorting and scoring
+// the index take one rather than reading the search box, whose query may be
+// edited underneath them.
+type matcher struct {
+	value  []rune
+	terms  []queryTerm
+	scorer int
+	base   bool
+}
+
+// Matcher snapshots the query as it is now.
+func (b *searchBox) Matcher() matcher {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	_, h := termbox.Size()
+	value := append([]rune(nil), b.value...)
+	return matcher{value: value, terms: parseQuery(value), scorer: b.scorer, base: b.matchBase}
+}
 
-	if h-3 > len(b.matches)-b.displayOffsetY {
-		return
-	}
+// key identifies the query against the index as of generation.
+func (m matcher) key(generation int) matchKey {
+	return matchKey{query: string(m.value), scorer: m.scorer, base: m.base, generation: generation}
+}
 
-	b.displayOffsetY++
+// Score scores path against the current query. Anything scoring many paths
+// should take a Matcher once instead.
+func (b *searchBox) Score(path string) float32 {
+	return b.Matcher().Score(path)
 }
 
-func (b *resultsBox) MouseScrollUp() {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+func (m matcher) Score(path string) float32 {
+	return m.scoreIndexed(path, filepath.Base(path))
+}
 
-	if b.displayOffsetY <= 0 {
-		return
+// scoreIndexed is Score for a path whose base name is already known.
+// TODO: prioritize whole word matching (ie: "site/site")
+func (m matcher) scoreIndexed(path, base string) float32 {
+	// everything matches an empty query equally
+	if len(m.value) == 0 {
+		return 1
+	}
+	// the basepath itself displays as "." and is only listed for an empty
+	// query, rather than whenever the query happens to be a subsequence of "."
+	if !readingList() && filepath.Clean(path) == filepath.Clean(search.basepath) {
+		return 0
 	}
+	var score float32
+	if m.base {
+		score = m.scoreText(base)
+	} else {
+		score = m.scoreText(matchFields.apply(search.relativePath(path)))
+	}
+	// a -symlinks link also matches on where it points
+	if target, ok := linkTargets.Get(path); ok {
+		if s := m.scoreText(target); s > score {
+			score = s
+		}
+	}
+	return extWeights.weight(path) * score
+}
 
-	b.displayOffsetY--
+// scoreText scores text against the query, from 0 for no match up to 1.
+func (m matcher) scoreText(text string) float32 {
+	// lowercase once and walk by offset; ToLower maps rune by rune, so this
+	// scores the same as lowering each remaining suffix
+	lower := strings.ToLower(normalize(text))
+	length := utf8.RuneCountInString(lower)
+	var score float32 = 1
+	scorer := scorers[m.scorer]
+	for _, term := range m.terms {
+		// a term can't match anything shorter than itself
+		if !term.negate && utf8.RuneCountInString(term.text) > length {
+			return 0
+		}
+		cost, ok := term.match(lower, scorer)
+		if term.negate {
+			if ok {
+				return 0
+			}
+			continue
+		}
+		if !ok {
+			return 0
+		}
+		score += cost
+	}
+	return 1 / score
 }
 
-func (b *resultsBox) MoveSelectionDownOne() {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+// queryTerm is one space-separated part of the query.
+type queryTerm struct {
+	text   string
+	negate bool // !term: exclude paths that match
+	prefix bool // ^term: the path must start with text
+	suffix bool // term$: the path must end with text
+}
 
-	if b.selected < len(b.matches)-1 {
-		b.selected++
+// parseQuery splits the query on spaces into terms that must all match. The
+// operators are structural and never part of a term's text: a leading !
+// negates a term, a leading ^ (after any !) anchors it to the start of the
+// path and a trailing $ to the end. A backslash makes the next operator, space
+// or backslash literal, wherever it is; any other backslash is kept as it is,
+// so Windows separators need no escaping. Case is ignored in the text alone.
+// Terms left empty, like a lone !, are ignored.
+func parseQuery(value []rune) []queryTerm {
+	var terms []queryTerm
+	var term queryTerm
+	var text []rune
+	end := func() {
+		if len(text) > 0 {
+			term.text = normalize(string(text))
+			terms = append(terms, term)
+		}
+		term, text = queryTerm{}, nil
+	}
+	for i := 0; i < len(value); i++ {
+		r := value[i]
+		last := i == len(value)-1 || value[i+1] == ' '
+		switch {
+		case r == ' ':
+			end()
+		case r == '\\' && i+1 < len(value) && strings.ContainsRune(`!^$ \`, value[i+1]):
+			i++
+			text = append(text, value[i])
+		case r == '!' && len(text) == 0 && !term.negate && !term.prefix:
+			term.negate = true
+		case r == '^' && len(text) == 0 && !term.prefix:
+			term.prefix = true
+		case r == '$' && last && len(text) > 0:
+			term.suffix = true
+		default:
+			text = append(text, r)
+		}
 	}
+	end()
+	return terms
+}
 
-	// selected is off screen up above
-	if b.selected < b.displayOffsetY {
-		b.focusTop()
+// match reports whether term matches lower, which must already be lowercase,
+// and at what cost, ignoring negation. Anchored and negated terms match
+// literally; the rest match as a subsequence.
+func (term queryTerm) match(lower, scorer string) (float32, bool) {
+	text := strings.ToLower(term.text)
+	cost := float32(len(text))
+	switch {
+	case term.prefix && term.suffix:
+		return cost, lower == text
+	case term.prefix:
+		return cost, strings.HasPrefix(lower, text)
+	case term.suffix:
+		return cost, strings.HasSuffix(lower, text)
+	case term.negate:
+		return cost, strings.Contains(lower, text)
 	}
+	// a term naming several segments can only match across them
+	if scorer == "components" && !strings.ContainsRune(text, filepath.Separator) {
+		return componentCost(lower, text)
+	}
+	return termCost(lower, text)
+}
 
-	// selected is off screen down below
-	_, h := termbox.Size()
-	if b.displayOffsetY+(h-4) < b.selected {
-		b.focusBottom()
+// segmentBoundary reports whether r ends a path segment or a word within one.
+func segmentBoundary(r rune) bool {
+	switch r {
+	case filepath.Separator, '-', '_', '.', ' ':
+		return true
 	}
+	return false
 }
 
-func (b *resultsBox) MoveSelectionUpOne() {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+// termCost is the cost of matching term as a subsequence of lower, which
+// must already be lowercase.
+func termCost(lower, term string) (float32, bool) {
+	var cost float32
+	var pos int
+	// whether the next rune of lower begins a path segment
+	segmentStart := true
+	for _, q := range term {
+		i := strings.IndexRune(lower[pos:], unicode.ToLower(q))
+		if i < 0 {
+			return 0, false
+		}
+		r, size := utf8.DecodeRuneInString(lower[pos+i:])
+		step := float32(i + size)
+		gap := lower[pos : pos+i]
+		// an initial, like the f in src/foo, skips the rest of the previous
+		// segment for free, so sfc finds src/foo/config before scattered
+		// matches within a single name
+		if last, _ := utf8.DecodeLastRuneInString(gap); i > 0 && segmentBoundary(last) {
+			step = float32(size)
+		}
+		if *boundaryBon > 0 {
+			if (i == 0 && segmentStart) || (i > 0 && gap[i-1] == filepath.Separator) {
+				step -= float32(*boundaryBon)
+			}
+			step -= float32(strings.Count(gap, string(filepath.Separator)))
+			if step < 0 {
+				step = 0
+			}
+		}
+		segmentStart = r == filepath.Separator
+		pos += i + size
+		cost += step
+	}
+	return cost, true
+}
 
-	if b.selected > 0 {
-		b.selected--
+// weightsFlag maps file extensions to score multipliers.
+type weightsFlag map[string]float32
+
+func (f weightsFlag) String() string {
+	var pairs []string
+	for ext, weight := range f {
+		pairs = append(pairs, fmt.Sprintf("%s=%g", ext, weight))
 	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
 
-	// selected is off screen up above
-	if b.selected < b.displayOffsetY {
-		b.focusTop()
+func (f weightsFlag) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("%q is not of the form ext=multiplier", pair)
+		}
+		weight, err := strconv.ParseFloat(kv[1], 32)
+		if err != nil || weight <= 0 {
+			return fmt.Errorf("%q is not a positive multiplier", kv[1])
+		}
+		ext := kv[0]
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		f[ext] = float32(weight)
 	}
+	return nil
+}
 
-	// selected is off screen down below
-	_, h := termbox.Size()
-	if b.displayOffsetY+(h-4) < b.selected {
-		b.focusBottom()
+// weight is the multiplier for path's extension, 1 if unlisted.
+func (f weightsFlag) weight(path string) float32 {
+	if weight, ok := f[filepath.Ext(path)]; ok {
+		return weight
 	}
+	return 1
 }
 
-func (b *resultsBox) AppendFilepaths(filepaths []string) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+// normalize puts s in NFC so that composed and decomposed forms of the same
+// text compare equal, additionally stripping accents under -ignore-accents.
+func normalize(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return s
+	}
+	if *noAccents {
+		// transformers are stateful, so each call gets its own chain
+		t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+		if stripped, _, err := transform.String(t, s); err == nil {
+			return stripped
+		}
+	}
+	return norm.NFC.String(s)
+}
 
-	a := b
-	_ = a
+// displayPath is path as listed: relative to the basepath, or in full once
+// toggled with ToggleAbsolute.
+func (b *searchBox) displayPath(path string) string {
+	// lines from stdin are shown as they were read, and -from lines too
+	// unless relativePath can shorten them
+	if readingList() {
+		return contractPath(displayFields.apply(b.relativePath(path)))
+	}
+	if b.Absolute() {
+		return contractPath(filepath.Clean(path))
+	}
+	return displayFields.apply(b.relativePath(path))
+}
 
-	all := append(b.filepaths, filepaths...)
-	sort.Slice(all, func(i, j int) bool {
-		si := search.Score(all[i])
-		sj := search.Score(all[j])
-		if si == sj {
-			if len(all[i]) == len(all[j]) {
-				return all[i] < all[j]
-			}
-			return len(all[i]) < len(all[j])
+// relativePath is path relative to the basepath, which is what queries match
+// against however paths are displayed.
+func (b *searchBox) relativePath(path string) string {
+	if *fromStdin {
+		return path
+	}
+	if *fromFile != "" {
+		// -from lines are only relative to the basepath if they lie beneath it
+		rel, err := filepath.Rel(filepath.Clean(b.basepath), filepath.Clean(path))
+		if !filepath.IsAbs(path) || err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return path
 		}
-		return si > sj
-	})
-	b.filepaths = all
-
-	go b.Recalculate()
+		return rel
+	}
+	// both paths are absolute, so cleaning only tidies separators and dots
+	rel, err := filepath.Rel(filepath.Clean(b.basepath), filepath.Clean(path))
+	if err != nil {
+		// showing the full path is better than failing to show it at all
+		log.Printf("%s: %v", path, err)
+		return path
+	}
+	return rel
 }
 
-func (b *resultsBox) Recalculate() {
+func (b *searchBox) Absolute() bool {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.matches = nil
-	for _, filepath := range b.filepaths {
-		score := search.Score(filepath)
-		if score > 0 {
-			b.matches = append(b.matches, filepath)
-		}
-	}
-	if b.selected >= len(b.matches) {
-		b.selected = len(b.matches) - 1
-	}
+	return b.absolute
 }
 
-func (b *resultsBox) SelectBestMatch() {
+// ToggleMatchBase switches between matching whole paths and base names.
+func (b *searchBox) ToggleMatchBase() {
+	b.mu.Lock()
+	b.matchBase = !b.matchBase
+	b.mu.Unlock()
+
+	go func() {
+		results.Recalculate()
+		results.SelectBestMatch()
+	}()
+}
+
+func (b *searchBox) MatchBase() bool {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	var bestScore float32
-	for i, match := range b.matches {
-		score := search.Score(match)
-		if score > bestScore {
-			bestScore = score
-			b.selected = i
-		}
-	}
+	return b.matchBase
 }
 
-func (b *resultsBox) Selected() string {
+func (b *searchBox) ToggleAbsolute() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if b.selected < 0 || len(b.matches) == 0 {
-		return "."
-	}
-	return b.matches[b.selected]
+	b.absolute = !b.absolute
 }
 
-func delim(r rune) bool {
-	switch r {
-	case '\\', '/', ' ', '.', '\t', ',', '-', '|':
-		return true
-	}
-	return false
+func (b *searchBox) MouseClick(x, y int) {
+	return
 }
 
-func word(r rune) bool {
-	return !delim(r)
+func (b *searchBox) Value() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return string(b.value)
 }
 
-type searchBox struct {
-	basepath      string
-	cursorOffsetX int
-	cursorOffsetY int
-	value         []rune
+func (b *searchBox) setValue(value string) {
+	b.value = []rune(value)
+	b.cursorOffsetX = len(b.value)
 
-	mu sync.Mutex
+	go func() {
+		results.Recalculate()
+		results.SelectBestMatch()
+	}()
 }
 
-func (b *searchBox) Draw() {
+// HistoryUp recalls the previous query. Browsing only begins from an empty
+// query while the selection is on the first row (atTop); it reports whether
+// the key was consumed.
+func (b *searchBox) HistoryUp(atTop bool) bool {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	label := b.basepath + string(filepath.Separator)
-	w, _ := termbox.Size()
-	termbox.SetCell(0, 0, '┌', termbox.ColorDefault, termbox.ColorDefault)
-	termbox.SetCell(0, 1, '│', termbox.ColorDefault, termbox.ColorDefault)
-	termbox.SetCell(0, 2, '└', termbox.ColorDefault, termbox.ColorDefault)
-	for i := 1; i < w-1; i++ {
-		termbox.SetCell(i, 0, '─', termbox.ColorDefault, termbox.ColorDefault)
-		termbox.SetCell(i, 2, '─', termbox.ColorDefault, termbox.ColorDefault)
-	}
-	termbox.SetCell(w-1, 0, '┐', termbox.ColorDefault, termbox.ColorDefault)
-	termbox.SetCell(w-1, 1, '│', termbox.ColorDefault, termbox.ColorDefault)
-	termbox.SetCell(w-1, 2, '┘', termbox.ColorDefault, termbox.ColorDefault)
-
-	for i, r := range label {
-		termbox.SetCell(i+1, 1, r, termbox.AttrBold, termbox.ColorDefault)
+	if b.historyIndex < 0 {
+		if len(b.value) > 0 || !atTop || len(b.history) == 0 {
+			return false
+		}
+		b.historyIndex = len(b.history)
 	}
-	for i, r := range b.value {
-		termbox.SetCell(len(label)+i+1, 1, r, termbox.ColorDefault, termbox.ColorDefault)
+	if b.historyIndex > 0 {
+		b.historyIndex--
 	}
-
-	termbox.SetCursor(len(label)+b.cursorOffsetX+1, b.cursorOffsetY+1)
+	b.setValue(b.history[b.historyIndex])
+	return true
 }
 
-// TODO: prioritize whole word matching (ie: "site/site")
-func (b *searchBox) Score(path string) float32 {
-	// everything matches an empty query equally
-	if len(b.value) == 0 {
-		return 1
+// HistoryDown recalls the next query, returning to an empty query after the
+// most recent one. It reports whether the key was consumed.
+func (b *searchBox) HistoryDown() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.historyIndex < 0 {
+		return false
 	}
-	partial := b.displayPath(path)
-	var score float32 = 1
-	var i int
-	for _, q := range b.value {
-		partial = strings.ToLower(partial[i:])
-		i = strings.IndexRune(partial, unicode.ToLower(q))
-		if i < 0 {
-			return 0
-		}
-		i++
-		score += float32(i)
+	b.historyIndex++
+	if b.historyIndex >= len(b.history) {
+		b.historyIndex = -1
+		b.setValue("")
+		return true
 	}
-	return 1 / score
+	b.setValue(b.history[b.historyIndex])
+	return true
 }
 
-func (b *searchBox) displayPath(path string) string {
-	rel, err := filepath.Rel(b.basepath, path)
-	if err != nil {
-		panic(err)
-	}
-	return rel
+// LeaveHistory keeps the recalled query as if it had been typed.
+func (b *searchBox) LeaveHistory() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.historyIndex = -1
 }
 
-func (b *searchBox) MouseClick(x, y int) {
-	return
+// saveHistory records query and, if ok, the path chosen with it.
+func saveHistory(query, path string, ok bool) {
+	st, err := loadState()
+	if err != nil {
+		log.Printf("loading state: %v", err)
+		return
+	}
+	ps := st.project(search.basepath)
+	ps.addHistory(query)
+	if ok {
+		ps.Last = path
+	}
+	if err := st.save(); err != nil {
+		log.Printf("saving state: %v", err)
+	}
 }
 
 func (b *searchBox) InsertRune(r rune) {
@@ -625,6 +2425,7 @@ func (b *searchBox) MoveCursorOneWordBackward() {
 
 	prefix := string(b.value[:b.cursorOffsetX])
 
+	delim, word := delims(*motionDelims)
 	// trim all delims then one word
 	prefix = strings.TrimRightFunc(prefix, delim)
 	prefix = strings.TrimRightFunc(prefix, word)
@@ -642,6 +2443,7 @@ func (b *searchBox) MoveCursorOneWordForward() {
 
 	suffix := string(b.value[b.cursorOffsetX:])
 
+	delim, word := delims(*motionDelims)
 	// trim all delims then one word
 	suffix = strings.TrimLeftFunc(suffix, delim)
 	suffix = strings.TrimLeftFunc(suffix, word)
@@ -676,6 +2478,7 @@ func (b *searchBox) DeleteWordBackward() {
 	prefix := string(b.value[:b.cursorOffsetX])
 	suffix := string(b.value[b.cursorOffsetX:])
 
+	delim, word := delims(*deleteDelims)
 	// trim all delims then one word
 	prefix = strings.TrimRightFunc(prefix, delim)
 	prefix = strings.TrimRightFunc(prefix, word)
@@ -688,6 +2491,21 @@ func (b *searchBox) DeleteWordBackward() {
 	}()
 }
 
+// Clear empties the query, leaving the best match for it selected.
+func (b *searchBox) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.value = []rune{}
+	b.cursorOffsetX = 0
+	b.displayOffsetX = 0
+
+	go func() {
+		results.Recalculate()
+		results.SelectBestMatch()
+	}()
+}
+
 func (b *searchBox) DeleteRuneForward() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -721,14 +2539,22 @@ func (b *debugBox) Draw() {
 
 	lines := strings.Split(string(b.buf.Bytes()), "\n")
 
-	w, h := termbox.Size()
+	// keep the most recent lines that fit under the search box, with the
+	// divider above them
+	room := resultRows() - 1
+	if room <= 0 {
+		return
+	}
+	if len(lines) > room {
+		lines = lines[len(lines)-room:]
+	}
+
+	w, h := viewSize()
 	for i := 0; i < w; i++ {
-		termbox.SetCell(i, h-len(lines)-1, '─', termbox.ColorDefault, termbox.ColorDefault)
+		setCell(i, h-len(lines)-1, '─', termbox.ColorDefault, termbox.ColorDefault)
 	}
 	for y, line := range lines {
-		for x, r := range line {
-			termbox.SetCell(x, h-len(lines)+y, r, termbox.ColorDefault, termbox.ColorDefault)
-		}
+		drawText(0, h-len(lines)+y, w, line, termbox.ColorDefault, termbox.ColorDefault)
 	}
 }
 
@@ -0,0 +1,1077 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// testW and testH are the size of the screen tests draw on.
+const testW, testH = 40, 14
+
+func TestMain(m *testing.M) {
+	// runNav runs the test binary as nav itself
+	if args, ok := os.LookupEnv("NAV_TEST_MAIN"); ok {
+		os.Args = append([]string{"nav"}, strings.Fields(args)...)
+		main()
+		os.Exit(0)
+	}
+	// there is no terminal to draw on, so boxes draw to memory when a test
+	// calls Draw, for screenRow to read back, and never otherwise
+	drawClosed = true
+	log.SetOutput(ioutil.Discard)
+	screen = &inlineScreen{w: testW, h: testH, rows: testH, cells: make([]termbox.Cell, testW*testH)}
+	os.Exit(m.Run())
+}
+
+// setQuery puts query in the search box, without the recalculation of the
+// global results that typing it would start in the background.
+func setQuery(query string) {
+	search.mu.Lock()
+	defer search.mu.Unlock()
+
+	search.value = []rune(query)
+	search.cursorOffsetX = len(search.value)
+}
+
+// matchesOf reads b's matches, which AppendFilepaths recalculates in the
+// background.
+func matchesOf(b *resultsBox) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.matches
+}
+
+// settle waits out the Recalculates that AppendFilepaths and RemoveFilepaths
+// start in the background, so they don't read flags a later test sets.
+// goroutines is how many were running before b was made.
+func settle(b *resultsBox, goroutines int) {
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > goroutines && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	b.mu.Lock()
+	b.mu.Unlock()
+}
+
+// bestChosen waits for the results to be recalculated and the best match
+// selected for the query with the current scorer, as F2 does in the
+// background.
+func bestChosen(t *testing.T) []string {
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		results.mu.Lock()
+		entry, ok := results.cache.Get(search.Matcher().key(results.generation))
+		matches := results.matches
+		results.mu.Unlock()
+		if ok && entry.best != "" {
+			return matches
+		}
+	}
+	t.Fatal("no best match chosen")
+	return nil
+}
+
+// TestAppendFilepathsRace appends to the index while the query is edited and
+// the matches are read, for go test -race to check.
+func TestAppendFilepathsRace(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	b := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	defer settle(b, runtime.NumGoroutine())
+
+	const n = 200
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			b.AppendFilepaths([]string{fmt.Sprintf("/base/dir%d", i)})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			setQuery("1")
+			setQuery("")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			b.Recalculate()
+			b.SelectBestMatch()
+			b.Counts()
+		}
+	}()
+	wg.Wait()
+
+	b.Recalculate()
+	matched, total, _, _ := b.Counts()
+	if total != n || matched != n {
+		t.Errorf("got %d of %d indexed paths matching, want %d of %d", matched, total, n, n)
+	}
+}
+
+// benchIndex is an index of n made-up paths under /base, five levels deep.
+func benchIndex(n int) []string {
+	words := []string{"src", "internal", "config", "test", "api", "users", "vendor", "docs", "cmd", "pkg"}
+	paths := make([]string, n)
+	for i := range paths {
+		segments := []string{"/base"}
+		for j, k := 0, i; j < 5; j, k = j+1, k/len(words) {
+			segments = append(segments, words[k%len(words)])
+		}
+		paths[i] = fmt.Sprintf("%s%d", strings.Join(segments, "/"), i)
+	}
+	return paths
+}
+
+// testMatcher matches query as the search box would, with the default scorer.
+func testMatcher(query string, base bool) matcher {
+	value := []rune(query)
+	return matcher{value: value, terms: parseQuery(value), base: base}
+}
+
+func BenchmarkMatchBasename(b *testing.B) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	index := &resultsBox{}
+	index.setIndex(benchIndex(100000))
+
+	for _, base := range []bool{false, true} {
+		name := "path"
+		if base {
+			name = "basename"
+		}
+		m := testMatcher("cfg", base)
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				scoreUntil(m, nil, index.filepaths, index.basenames, func() bool { return false })
+			}
+		})
+	}
+}
+
+// clicks reports whether clicking column x of row y picks b's selection.
+func clicks(b *resultsBox, x, y int) bool {
+	events := make(chan event, 1)
+	b.MouseClick(x, y, events)
+	select {
+	case ev := <-events:
+		return ev.evType == EventSelected
+	case <-time.After(50 * time.Millisecond):
+		return false
+	}
+}
+
+func TestMouseClick(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	defer func(compacted, numbers bool) { *compact, *lineNumbers = compacted, numbers }(*compact, *lineNumbers)
+	row := viewLayout().results
+
+	// nothing selected, or a selection left over from a longer list
+	for _, selected := range []int{-1, 1} {
+		b := &resultsBox{selected: selected, matches: []string{"/base/src"}}
+		if clicks(b, 3, row+selected) {
+			t.Errorf("a click picked selection %d of one match", selected)
+		}
+	}
+
+	// the path starts right after the gutter, whatever it holds
+	for _, tt := range []struct{ compact, numbers bool }{
+		{false, false},
+		{false, true},
+		{true, false},
+		{true, true},
+	} {
+		*compact, *lineNumbers = tt.compact, tt.numbers
+		b := &resultsBox{matches: []string{"/base/src", "/base/docs"}}
+		gutter := b.gutterWidth()
+		if gutter > 0 && clicks(b, gutter-1, row) {
+			t.Errorf("%+v: a click on the gutter's last column, %d, picked the path", tt, gutter-1)
+		}
+		if !clicks(b, gutter, row) {
+			t.Errorf("%+v: a click on the path's first column, %d, didn't pick it", tt, gutter)
+		}
+		if !clicks(b, gutter+len("src")-1, row) || clicks(b, gutter+len("src"), row) {
+			t.Errorf("%+v: clicks either side of the end of the path, at %d, missed", tt, gutter+len("src"))
+		}
+		if clicks(b, gutter, row+1) {
+			t.Errorf("%+v: a click on an unselected row picked the selection", tt)
+		}
+	}
+}
+
+func TestInitBasepathFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer flag.CommandLine.Parse(nil)
+	flag.CommandLine.Parse([]string{file})
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(stderr *os.File) { os.Stderr = stderr }(os.Stderr)
+	os.Stderr = w
+
+	basepath, err := initBasepath()
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if basepath != dir {
+		t.Errorf("basepath is %s, want the file's directory %s", basepath, dir)
+	}
+	msg, _ := ioutil.ReadAll(r)
+	if want := fmt.Sprintf("nav: %s is not a directory, using %s\n", file, dir); string(msg) != want {
+		t.Errorf("printed %q, want %q", msg, want)
+	}
+}
+
+// TestScoreTextCase checks that lowering a candidate once scores it the same
+// as lowering it up front, as the per-rune lowering did.
+func TestScoreTextCase(t *testing.T) {
+	m := testMatcher("srcCfg", false)
+	for _, text := range []string{"src/config", "Src/Config", "SRC/CONFIG", "ÉTÉ/src/config", "vendor/Src/x/CFG"} {
+		if got, want := m.scoreText(text), m.scoreText(strings.ToLower(text)); got != want || got == 0 {
+			t.Errorf("%s scored %v, want the lowercase %v", text, got, want)
+		}
+	}
+}
+
+func BenchmarkScoreText(b *testing.B) {
+	paths := benchIndex(10000)
+	m := testMatcher("cfg", false)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range paths {
+			m.scoreText(path)
+		}
+	}
+}
+
+func TestEmptyQueryRecalculate(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	b := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	paths := []string{"/base/docs", "/base/src/api", "/base/src"}
+	sortByScore(paths, testMatcher("", false))
+	b.setIndex(paths)
+
+	b.Recalculate()
+	if want := []string{"/base/src", "/base/docs", "/base/src/api"}; !reflect.DeepEqual(b.matches, want) {
+		t.Errorf("empty query: got %v, want %v, shortest first", b.matches, want)
+	}
+	setQuery("a")
+	b.Recalculate()
+	if want := []string{"/base/src/api"}; !reflect.DeepEqual(b.matches, want) {
+		t.Errorf("query a: got %v, want %v", b.matches, want)
+	}
+	setQuery("")
+	b.Recalculate()
+	if len(b.matches) != 3 {
+		t.Errorf("cleared query: got %v, want every path", b.matches)
+	}
+}
+
+// BenchmarkEmptyQuery orders and matches the index for an empty query, and
+// for a lone space, which scores every path the same as the empty query does
+// but without the shortcut.
+func BenchmarkEmptyQuery(b *testing.B) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	index := benchIndex(100000)
+
+	for _, query := range []string{"", " "} {
+		name := "empty"
+		if query != "" {
+			name = "scored"
+		}
+		m := testMatcher(query, false)
+		b.Run(name, func(b *testing.B) {
+			paths := make([]string, len(index))
+			for i := 0; i < b.N; i++ {
+				copy(paths, index)
+				sortByScore(paths, m)
+				matching(paths, m)
+			}
+		})
+	}
+}
+
+func TestDisplayPathClean(t *testing.T) {
+	b := &searchBox{basepath: "/base/"}
+	for path, want := range map[string]string{
+		"/base":                ".",
+		"/base//src/./api/":    "src/api",
+		"/base/src/../docs":    "docs",
+		"/base/src//internal/": "src/internal",
+	} {
+		if got := b.displayPath(filepath.FromSlash(path)); got != filepath.FromSlash(want) {
+			t.Errorf("%s displayed as %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	composed, decomposed := "café", "café"
+	if normalize(decomposed) != composed {
+		t.Errorf("%q normalized to %q, want %q", decomposed, normalize(decomposed), composed)
+	}
+	if testMatcher(composed, false).scoreText("docs/"+decomposed) == 0 {
+		t.Error("a composed query didn't match a decomposed path")
+	}
+	if testMatcher("cafe", false).scoreText("docs/"+composed) != 0 {
+		t.Error("cafe matched café without -ignore-accents")
+	}
+
+	defer func(accents bool) { *noAccents = accents }(*noAccents)
+	*noAccents = true
+	for _, path := range []string{composed, decomposed} {
+		if testMatcher("cafe", false).scoreText("docs/"+path) == 0 {
+			t.Errorf("cafe didn't match %q with -ignore-accents", path)
+		}
+	}
+}
+
+func TestExtWeights(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	m := testMatcher("main", false)
+	paths := []string{"/base/cmd/main.go", "/base/main.md"}
+	sortByScore(paths, m)
+	if paths[0] != "/base/main.md" {
+		t.Fatalf("unweighted: got %v, want main.md, the closer match, first", paths)
+	}
+
+	defer func() {
+		for ext := range extWeights {
+			delete(extWeights, ext)
+		}
+	}()
+	if err := extWeights.Set("go=2,.md=0.5"); err != nil {
+		t.Fatal(err)
+	}
+	sortByScore(paths, m)
+	if paths[0] != "/base/cmd/main.go" {
+		t.Errorf("weighted: got %v, want main.go first", paths)
+	}
+	if w := extWeights.weight("/base/README"); w != 1 {
+		t.Errorf("an unlisted extension weighs %v, want 1", w)
+	}
+
+	for _, bad := range []string{".go", ".go=x", ".go=0", ".go=-1"} {
+		if err := (weightsFlag{}).Set(bad); err == nil {
+			t.Errorf("%q parsed without error", bad)
+		}
+	}
+}
+
+func TestTermCostBoundaryBonus(t *testing.T) {
+	defer func(bonus float64) { *boundaryBon = bonus }(*boundaryBon)
+	for _, tt := range []struct {
+		lower, term string
+		bonus       float64
+		want        float32
+	}{
+		{"src/api", "sa", 0, 2},
+		{"src/api", "sa", 1, 0},
+		// separators are free to cross with a bonus
+		{"a/b/c", "c", 0, 1},
+		{"a/b/c", "c", 1, 0},
+		// but matches inside a segment still cost what they skip
+		{"abc", "c", 0, 3},
+		{"abc", "c", 1, 3},
+	} {
+		*boundaryBon = tt.bonus
+		cost, ok := termCost(filepath.FromSlash(tt.lower), tt.term)
+		if !ok || cost != tt.want {
+			t.Errorf("%q in %q with -boundary-bonus %v: cost %v, %v; want %v", tt.term, tt.lower, tt.bonus, cost, ok, tt.want)
+		}
+	}
+}
+
+// clearScreen blanks the test screen.
+func clearScreen() {
+	for i := range screen.cells {
+		screen.cells[i] = termbox.Cell{Ch: ' '}
+	}
+	screen.cursorX, screen.cursorY = -1, -1
+}
+
+// screenRow is the text of row y of the test screen, without trailing spaces.
+func screenRow(y int) string {
+	var row []rune
+	for _, c := range screen.cells[y*screen.w : (y+1)*screen.w] {
+		row = append(row, c.Ch)
+	}
+	return strings.TrimRight(string(row), " ")
+}
+
+func TestLongQuery(t *testing.T) {
+	clearScreen()
+	query := []rune(strings.Repeat("abcdefghijklmnopqrstuvwxyz", 2))
+	b := &searchBox{basepath: "/b", value: query, cursorOffsetX: len(query)}
+	b.Draw()
+
+	// the label takes columns 1 to 3, leaving all but one of the rest for
+	// the end of the query and the last for the cursor before the border
+	shown := testW - 6
+	if got, want := screenRow(1), "│/b/"+string(query[len(query)-shown:])+" │"; got != want {
+		t.Errorf("drew %q, want %q", got, want)
+	}
+	if want := 4 + shown; screen.cursorX != want {
+		t.Errorf("cursor at column %d, want %d, inside the box", screen.cursorX, want)
+	}
+
+	if testMatcher(string(query), false).scoreText("src/api") != 0 {
+		t.Error("a query longer than the path matched it")
+	}
+}
+
+func TestPlaceholder(t *testing.T) {
+	clearScreen()
+	b := &searchBox{basepath: "/b"}
+	b.Draw()
+	row := screenRow(1)
+	if !strings.Contains(row, "/b/"+*placeholder) {
+		t.Errorf("row 1 is %q, want the placeholder after the label", row)
+	}
+	x := len([]rune(row[:strings.Index(row, *placeholder)]))
+	if c := screen.cells[screen.w+x]; c.Fg != termbox.ColorDefault {
+		t.Errorf("the placeholder is drawn with %v, want the default color", c.Fg)
+	}
+
+	clearScreen()
+	b.value, b.cursorOffsetX = []rune("src"), 3
+	b.Draw()
+	if strings.Contains(screenRow(1), *placeholder) {
+		t.Error("the placeholder is still shown with a query")
+	}
+}
+
+func TestParentOf(t *testing.T) {
+	search.basepath = filepath.FromSlash("/base")
+	defer func() { search.basepath = "" }()
+	for path, want := range map[string]string{
+		"/base/src/api": "/base/src",
+		"/base/src":     "/base",
+		"/base":         "/base",
+	} {
+		if got := parentOf(filepath.FromSlash(path)); got != filepath.FromSlash(want) {
+			t.Errorf("parent of %s is %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestScoreBasepath(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	paths := []string{"/base", "/base/src"}
+	if got := matching(paths, testMatcher("", false)); len(got) != 2 {
+		t.Errorf("empty query: got %v, want the basepath listed too", got)
+	}
+	if s := testMatcher(".", false).Score("/base/"); s != 0 {
+		t.Errorf("the basepath scored %v for a query of ., want 0", s)
+	}
+}
+
+// TestScrollOff moves through the list checking that -scrolloff rows stay
+// visible either side of the selection, short of the ends of the list.
+func TestScrollOff(t *testing.T) {
+	defer func(off int) { *scrollOff = off }(*scrollOff)
+	*scrollOff = 2
+	rows := resultRows()
+
+	b := &resultsBox{matches: benchIndex(30)}
+	check := func() {
+		above, below := b.selected-b.displayOffsetY, b.displayOffsetY+rows-1-b.selected
+		if (above < 2 && b.displayOffsetY > 0) || (below < 2 && b.displayOffsetY+rows < len(b.matches)) || above < 0 || below < 0 {
+			t.Fatalf("selected %d with rows %d to %d showing", b.selected, b.displayOffsetY, b.displayOffsetY+rows-1)
+		}
+	}
+	for i := 0; i < len(b.matches); i++ {
+		b.MoveSelectionDownOne()
+		check()
+	}
+	if b.displayOffsetY != len(b.matches)-rows {
+		t.Errorf("at the end, showing from row %d, want %d", b.displayOffsetY, len(b.matches)-rows)
+	}
+	for i := 0; i < len(b.matches); i++ {
+		b.MoveSelectionUpOne()
+		check()
+	}
+
+	// the margin is limited to what fits either side of the selection
+	*scrollOff = 100
+	if m := b.scrollMargin(); m != (rows-1)/2 {
+		t.Errorf("margin %d, want %d", m, (rows-1)/2)
+	}
+}
+
+func TestSelectionFollowsPath(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	b := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	defer settle(b, runtime.NumGoroutine())
+	b.AppendFilepaths([]string{"/base/docs", "/base/src/api"})
+	b.Recalculate()
+	b.MoveSelectionDownOne()
+	want, _ := b.Selection()
+
+	// shorter paths arrive and sort ahead of the selection
+	b.AppendFilepaths([]string{"/base/a", "/base/b"})
+	b.Recalculate()
+	if got, _ := b.Selection(); got != want {
+		t.Errorf("selection moved from %s to %s as paths arrived", want, got)
+	}
+}
+
+func TestWalkQuit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, sub := range []string{"a/b", "c/d"} {
+		os.MkdirAll(filepath.Join(dir, filepath.FromSlash(sub)), 0755)
+	}
+
+	defer func(q chan struct{}) { quit = q }(quit)
+	quit = make(chan struct{})
+	batches := make(chan []string)
+	go walk(dir, batches)
+	// nothing takes the first batch, as when the picker exits mid-walk
+	close(quit)
+
+	done := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-batches:
+			if !ok {
+				return
+			}
+		case <-done:
+			t.Fatal("the walk carried on after quit was closed")
+		}
+	}
+}
+
+// TestNoDrawAfterShutdown checks that once shutdown has run, nothing more is
+// drawn, whatever is still going on in the background.
+func TestNoDrawAfterShutdown(t *testing.T) {
+	defer func(q chan struct{}) { quit = q }(quit)
+	quit = make(chan struct{})
+	drawMutex.Lock()
+	drawClosed = false
+	drawMutex.Unlock()
+	shutdown()
+
+	for i := range screen.cells {
+		screen.cells[i] = termbox.Cell{Ch: 'x'}
+	}
+	before := append([]termbox.Cell(nil), screen.cells...)
+	results.Recalculate()
+	draw()
+	repaint()
+	// draw renders in the background
+	time.Sleep(50 * time.Millisecond)
+	drawMutex.Lock()
+	defer drawMutex.Unlock()
+	if !reflect.DeepEqual(screen.cells, before) {
+		t.Error("the screen was drawn on after shutdown")
+	}
+}
+
+func TestStartupErrors(t *testing.T) {
+	defer flag.CommandLine.Parse(nil)
+	flag.CommandLine.Parse([]string{filepath.Join(os.TempDir(), "nav-missing", "dir")})
+	if path, err := initBasepath(); err == nil || path != "" {
+		t.Errorf("a missing basepath gave %q, %v; want an error", path, err)
+	}
+
+	// a second failure is dropped rather than blocking its walker
+	reportError(errors.New("first"))
+	reportError(errors.New("second"))
+	if err := <-walkErrors; err.Error() != "first" {
+		t.Errorf("reported %v, want the first error", err)
+	}
+
+	// relative paths can't be made relative to an absolute basepath
+	b := &searchBox{basepath: "/base"}
+	if got := b.relativePath("src"); got != "src" {
+		t.Errorf("got %q, want the path as it was", got)
+	}
+}
+
+func TestNegatedTerms(t *testing.T) {
+	if got, want := parseQuery([]rune("config !test")), []queryTerm{{text: "config"}, {text: "test", negate: true}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parsed %+v, want %+v", got, want)
+	}
+	m := testMatcher("config !test", false)
+	for path, want := range map[string]bool{
+		"src/config":      true,
+		"test/config":     false,
+		"config/testdata": false,
+		"config/tset":     true,
+	} {
+		if got := m.scoreText(path) > 0; got != want {
+			t.Errorf("%s matched %v, want %v", path, got, want)
+		}
+	}
+	// a lone ! is no term at all
+	if got := parseQuery([]rune("! api")); len(got) != 1 {
+		t.Errorf("parsed %+v, want just api", got)
+	}
+}
+
+func TestAnchoredTerms(t *testing.T) {
+	if got, want := parseQuery([]rune("^src api$ !^vendor")), []queryTerm{
+		{text: "src", prefix: true},
+		{text: "api", suffix: true},
+		{text: "vendor", negate: true, prefix: true},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parsed %+v, want %+v", got, want)
+	}
+	for _, tt := range []struct {
+		query, path string
+		want        bool
+	}{
+		{"^src", "src/api", true},
+		{"^src", "lib/src", false},
+		{"api$", "src/api", true},
+		{"api$", "api/src", false},
+		{"^src/api$", "src/api", true},
+		{"^src/api$", "src/api/v2", false},
+		{"!^vendor", "vendor/x", false},
+		{"!^vendor", "x/vendor", true},
+	} {
+		if got := testMatcher(tt.query, false).scoreText(filepath.FromSlash(tt.path)) > 0; got != tt.want {
+			t.Errorf("%s against %s matched %v, want %v", tt.query, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExpandTilde(t *testing.T) {
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", filepath.FromSlash("/home/nav"))
+	for path, want := range map[string]string{
+		"~":        "/home/nav",
+		"~/src":    "/home/nav/src",
+		"src/~":    "src/~",
+		"/tmp/~/x": "/tmp/~/x",
+	} {
+		got, err := expandTilde(filepath.FromSlash(path))
+		if err != nil || got != filepath.FromSlash(want) {
+			t.Errorf("%s expanded to %q, %v; want %q", path, got, err, want)
+		}
+	}
+	if got, err := expandTilde("~nav-no-such-user"); err == nil {
+		t.Errorf("an unknown user expanded to %q", got)
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+	if got, err := expandTilde("~" + u.Username); err != nil || got != u.HomeDir {
+		t.Errorf("~%s expanded to %q, %v; want %q", u.Username, got, err, u.HomeDir)
+	}
+}
+
+func TestSortShortest(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	defer func(mode string) { *sortMode = mode }(*sortMode)
+	m := testMatcher("api", false)
+	paths := func() []string {
+		return []string{"/base/x/y/z/api", "/base/apxi", "/base/src/api"}
+	}
+
+	*sortMode = "score"
+	byScore := paths()
+	sortByScore(byScore, m)
+	if want := []string{"/base/src/api", "/base/x/y/z/api", "/base/apxi"}; !reflect.DeepEqual(byScore, want) {
+		t.Errorf("-sort score: got %v, want %v", byScore, want)
+	}
+
+	*sortMode = "shortest"
+	shortest := paths()
+	sortByScore(shortest, m)
+	if want := []string{"/base/apxi", "/base/src/api", "/base/x/y/z/api"}; !reflect.DeepEqual(shortest, want) {
+		t.Errorf("-sort shortest: got %v, want %v", shortest, want)
+	}
+}
+
+func TestInitials(t *testing.T) {
+	for _, tt := range []struct {
+		lower, term string
+		want        float32
+	}{
+		// each initial costs only itself
+		{"src/foo/config", "sfc", 3},
+		{"user-api_v2.go", "uav", 3},
+		// while scattered matches pay for what they skip
+		{"sxfxc", "sfc", 5},
+	} {
+		cost, ok := termCost(filepath.FromSlash(tt.lower), tt.term)
+		if !ok || cost != tt.want {
+			t.Errorf("%q in %q: cost %v, %v; want %v", tt.term, tt.lower, cost, ok, tt.want)
+		}
+	}
+	m := testMatcher("sfc", false)
+	if initials, scattered := m.scoreText(filepath.FromSlash("src/foo/config")), m.scoreText("sxfxcxxxx"); initials <= scattered {
+		t.Errorf("initials scored %v, not above the scattered %v", initials, scattered)
+	}
+}
+
+func TestWalkBreadthFirst(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, sub := range []string{"a/b/c/d", "e/f", "g"} {
+		os.MkdirAll(filepath.Join(dir, filepath.FromSlash(sub)), 0755)
+	}
+
+	batches := make(chan []string)
+	go walk(dir, batches)
+	var found []string
+	depth := 0
+	for batch := range batches {
+		for _, path := range batch {
+			rel, _ := filepath.Rel(dir, path)
+			d := strings.Count(rel, string(filepath.Separator))
+			if d < depth {
+				t.Errorf("%s listed after a deeper directory", rel)
+			}
+			depth = d
+			found = append(found, filepath.ToSlash(rel))
+		}
+	}
+	sort.Strings(found)
+	if want := []string{"a", "a/b", "a/b/c", "a/b/c/d", "e", "e/f", "g"}; !reflect.DeepEqual(found, want) {
+		t.Errorf("found %v, want %v", found, want)
+	}
+}
+
+func TestResumeSelection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_STATE_HOME", os.Getenv("XDG_STATE_HOME"))
+	os.Setenv("XDG_STATE_HOME", dir)
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+
+	saveHistory("api", "/base/src/api", true)
+	st, err := loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := st.project("/base").Last
+	if last != "/base/src/api" {
+		t.Fatalf("saved %q as the last selection", last)
+	}
+
+	b := &resultsBox{preselect: -1, resume: last, initDone: make(chan struct{})}
+	defer settle(b, runtime.NumGoroutine())
+	b.AppendFilepaths([]string{"/base/docs", "/base/src", "/base/src/api"})
+	b.Recalculate()
+	if got, _ := b.Selection(); got != last {
+		t.Errorf("started on %s, want %s", got, last)
+	}
+	b.CancelPreselect()
+	b.MoveSelectionUpOne()
+	b.Recalculate()
+	if got, _ := b.Selection(); got == last {
+		t.Error("the resumed selection was reapplied after the user moved")
+	}
+}
+
+func TestClampView(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	b := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	defer settle(b, runtime.NumGoroutine())
+	paths := []string{"/base/api1", "/base/api2"}
+	for i := 0; i < 28; i++ {
+		paths = append(paths, fmt.Sprintf("/base/dir%d", i))
+	}
+	b.AppendFilepaths(paths)
+	b.Recalculate()
+	for range paths {
+		b.MoveSelectionDownOne()
+	}
+	b.mu.Lock()
+	scrolled := b.displayOffsetY > 0
+	b.mu.Unlock()
+	if !scrolled {
+		t.Fatal("the view didn't scroll down the list")
+	}
+
+	setQuery("api")
+	defer setQuery("")
+	b.Recalculate()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.matches) != 2 || b.displayOffsetY != 0 {
+		t.Errorf("%d matches shown from row %d, want 2 from row 0", len(b.matches), b.displayOffsetY)
+	}
+}
+
+func TestQueryEscapes(t *testing.T) {
+	for query, want := range map[string][]queryTerm{
+		`\!important`: {{text: "!important"}},
+		`\^caret`:     {{text: "^caret"}},
+		`cost\$`:      {{text: "cost$"}},
+		`my\ docs`:    {{text: "my docs"}},
+		`a\\b`:        {{text: `a\b`}},
+		// other backslashes are kept, for Windows separators
+		`src\api`: {{text: `src\api`}},
+		// the text keeps its case, which matching ignores
+		`!^Vendor`: {{text: "Vendor", negate: true, prefix: true}},
+	} {
+		if got := parseQuery([]rune(query)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s parsed as %+v, want %+v", query, got, want)
+		}
+	}
+	if testMatcher(`\!`, false).scoreText("src") != 0 || testMatcher(`\!`, false).scoreText("!bang") == 0 {
+		t.Error(`\! should match a literal ! and nothing else`)
+	}
+}
+
+func TestDebugBoxFits(t *testing.T) {
+	defer os.Setenv("DEBUG", os.Getenv("DEBUG"))
+	os.Setenv("DEBUG", "1")
+	clearScreen()
+	b := &debugBox{buf: &bytes.Buffer{}}
+	var lines []string
+	for i := 0; i < 50; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	b.Write([]byte(strings.Join(lines, "\n")))
+	b.Draw()
+
+	// the divider and the latest lines take the rows below the status row
+	room := resultRows() - 1
+	if got := screenRow(testH - room - 1); got != strings.Repeat("─", testW) {
+		t.Errorf("row %d is %q, want the divider", testH-room-1, got)
+	}
+	for y := 0; y < room; y++ {
+		if got, want := screenRow(testH-room+y), lines[len(lines)-room+y]; got != want {
+			t.Errorf("row %d is %q, want %q", testH-room+y, got, want)
+		}
+	}
+	if got := screenRow(viewLayout().status); got != "" {
+		t.Errorf("the status row was drawn over with %q", got)
+	}
+}
+
+func TestCapIndex(t *testing.T) {
+	defer func(max int) { *maxIndex = max }(*maxIndex)
+	more := []string{"a", "b", "c"}
+
+	*maxIndex = 0
+	if got := capIndex(100, more); len(got) != 3 {
+		t.Errorf("uncapped: kept %v, want all of them", got)
+	}
+	*maxIndex = 5
+	for have, want := range map[int]int{0: 3, 2: 3, 3: 2, 4: 1, 5: 0, 9: 0} {
+		if got := capIndex(have, more); len(got) != want {
+			t.Errorf("-max-index 5 holding %d: kept %v, want %d", have, got, want)
+		}
+	}
+}
+
+func TestMotionDelims(t *testing.T) {
+	defer func(delims string) { *motionDelims = delims }(*motionDelims)
+	query := []rune("src/nav cmd")
+	b := &searchBox{value: query, cursorOffsetX: len(query)}
+
+	var stops []int
+	for i := 0; i < 3; i++ {
+		b.MoveCursorOneWordBackward()
+		stops = append(stops, b.cursorOffsetX)
+	}
+	if want := []int{8, 4, 0}; !reflect.DeepEqual(stops, want) {
+		t.Errorf("Alt-B stopped at %v, want %v", stops, want)
+	}
+
+	// leaving out / moves over whole paths
+	*motionDelims = " "
+	stops = nil
+	for i := 0; i < 2; i++ {
+		b.MoveCursorOneWordForward()
+		stops = append(stops, b.cursorOffsetX)
+	}
+	if want := []int{7, 11}; !reflect.DeepEqual(stops, want) {
+		t.Errorf("Alt-F stopped at %v, want %v", stops, want)
+	}
+}
+
+func TestDeleteDelims(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	defer func(delims string) { *deleteDelims = delims }(*deleteDelims)
+	defer setQuery("")
+	// a match for each query lets bestChosen see the deletion through
+	results.mu.Lock()
+	results.setIndex([]string{filepath.FromSlash("/base/src/nav")})
+	results.mu.Unlock()
+	defer func() {
+		results.mu.Lock()
+		results.setIndex(nil)
+		results.matches = nil
+		results.mu.Unlock()
+	}()
+
+	*deleteDelims = " "
+	setQuery("src/nav cm")
+	search.DeleteWordBackward()
+	bestChosen(t)
+	if got := search.Value(); got != "src/nav " {
+		t.Errorf("Alt-Backspace left %q, want %q", got, "src/nav ")
+	}
+
+	*deleteDelims = defaultDelims
+	search.DeleteWordBackward()
+	bestChosen(t)
+	if got := search.Value(); got != "src/" {
+		t.Errorf("Alt-Backspace left %q, want %q", got, "src/")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	defer func(on bool) { *compact = on }(*compact)
+	b := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	b.setIndex([]string{filepath.FromSlash("/base/src"), filepath.FromSlash("/base/docs")})
+	b.Recalculate()
+	top := viewLayout().results
+
+	clearScreen()
+	b.Draw()
+	if got := screenRow(top); got != "► src" {
+		t.Errorf("selected row is %q, want the marker and the path", got)
+	}
+
+	*compact = true
+	clearScreen()
+	b.Draw()
+	if got, next := screenRow(top), screenRow(top+1); got != "src" || next != "docs" {
+		t.Errorf("rows are %q and %q, want the paths in the first column", got, next)
+	}
+	if fg := screen.cells[top*testW].Fg; fg&termbox.AttrReverse == 0 {
+		t.Error("the selection isn't reversed without its marker")
+	}
+
+	// presses and clicks land on the rows as drawn, from the first column
+	var paths []string
+	for i := 0; i < 2*resultRows(); i++ {
+		paths = append(paths, filepath.FromSlash(fmt.Sprintf("/base/dir%02d", i)))
+	}
+	b.setIndex(paths)
+	b.Recalculate()
+	clearScreen()
+	b.Draw()
+	last := top + resultRows() - 1
+	for _, y := range []int{top, last} {
+		b.MousePress(y)
+		want := filepath.Join(search.basepath, screenRow(y))
+		if got, _ := b.Selection(); got != want {
+			t.Errorf("pressing row %d selected %s, want %s", y, got, want)
+		}
+		if !clicks(b, 0, y) {
+			t.Errorf("a click on the first column of row %d didn't pick it", y)
+		}
+		if other := top + last - y; clicks(b, 0, other) {
+			t.Errorf("with row %d selected, a click on row %d picked it", y, other)
+		}
+	}
+}
+
+func TestAlignNotes(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	defer func(on bool) { *alignNotes = on }(*alignNotes)
+	defer func(targets *targetMap) { linkTargets = targets }(linkTargets)
+	defer func(w int) { screen.w = w }(screen.w)
+	linkTargets = &targetMap{targets: map[string]string{}}
+
+	src, api := filepath.FromSlash("/base/src"), filepath.FromSlash("/base/docs/api")
+	b := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	b.setIndex([]string{src, api, filepath.FromSlash("/base/a")})
+	b.MarkTruncated(src)
+	linkTargets.Set(api, "/x")
+	b.Recalculate()
+	top := viewLayout().results
+
+	for _, tt := range []struct {
+		align bool
+		w     int
+		rows  []string
+	}{
+		{false, testW, []string{
+			"► src (truncated)",
+			"  docs/api -> /x",
+			"  a",
+		}},
+		// the notes start one column after the longest path that has any
+		{true, testW, []string{
+			"► src      (truncated)",
+			"  docs/api -> /x",
+			"  a",
+		}},
+		// a narrow screen cuts the notes off, not the paths
+		{true, 14, []string{
+			"► src      (tr",
+			"  docs/api -> ",
+			"  a",
+		}},
+	} {
+		*alignNotes = tt.align
+		screen.w = tt.w
+		clearScreen()
+		b.Draw()
+		for i, want := range tt.rows {
+			y := top + i
+			want += strings.Repeat(" ", screen.w-textWidth([]rune(want)))
+			for x, r := range []rune(want) {
+				if got := screen.cells[y*screen.w+x].Ch; got != r {
+					t.Errorf("align %v, width %d: cell %d of row %d is %q, want %q in %q", tt.align, tt.w, x, y, got, r, want)
+					break
+				}
+			}
+		}
+	}
+}
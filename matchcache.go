@@ -0,0 +1,75 @@
+package main
+
+// matchCacheSize is how many query results are kept.
+const matchCacheSize = 32
+
+// matchCache remembers the matches, and the best of them, for recent queries,
+// so that deleting back to an earlier query doesn't rescore the whole index.
+//
+// Entries are keyed by the query text, how it matches, and the index
+// generation, which resultsBox bumps whenever paths are added or removed. An
+// edit that returns to an earlier query finds its entry again, while any
+// change to the index makes every existing entry stale; those are dropped on
+// the next Put, since generations only increase.
+type matchCache struct {
+	entries map[matchKey]*matchCacheEntry
+	// order lists the cached keys, least recently used first
+	order []matchKey
+}
+
+type matchKey struct {
+	query      string
+	scorer     int
+	base       bool
+	generation int
+}
+
+type matchCacheEntry struct {
+	matches []string
+	// best is the highest scoring match, or "" until SelectBestMatch has run
+	best string
+}
+
+// Get returns the entry for key, marking it as recently used.
+func (c *matchCache) Get(key matchKey) (*matchCacheEntry, bool) {
+	entry, ok := c.entries[key]
+	if ok {
+		c.touch(key)
+	}
+	return entry, ok
+}
+
+// Put caches matches for key, dropping stale generations and then the least
+// recently used entry once the cache is full.
+func (c *matchCache) Put(key matchKey, matches []string) *matchCacheEntry {
+	if c.entries == nil {
+		c.entries = map[matchKey]*matchCacheEntry{}
+	}
+	order := c.order[:0]
+	for _, k := range c.order {
+		if k.generation < key.generation {
+			delete(c.entries, k)
+			continue
+		}
+		order = append(order, k)
+	}
+	c.order = order
+	if _, ok := c.entries[key]; !ok && len(c.order) >= matchCacheSize {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	entry := &matchCacheEntry{matches: matches}
+	c.entries[key] = entry
+	c.touch(key)
+	return entry
+}
+
+func (c *matchCache) touch(key matchKey) {
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, key)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMatchCache(t *testing.T) {
+	var c matchCache
+	key := func(query string, generation int) matchKey {
+		return matchKey{query: query, generation: generation}
+	}
+	for i := 0; i < matchCacheSize; i++ {
+		c.Put(key(fmt.Sprint(i), 1), []string{fmt.Sprint(i)})
+	}
+	// using the oldest entry saves it from eviction, leaving the next oldest
+	c.Get(key("0", 1))
+	c.Put(key("new", 1), nil)
+	if _, ok := c.Get(key("0", 1)); !ok {
+		t.Error("the recently used entry was evicted")
+	}
+	if _, ok := c.Get(key("1", 1)); ok {
+		t.Error("the least recently used entry is still cached")
+	}
+	if entry, ok := c.Get(key("2", 1)); !ok || entry.matches[0] != "2" {
+		t.Errorf("got %v, want the matches for 2", entry)
+	}
+
+	// a change to the index makes every entry stale
+	c.Put(key("0", 2), nil)
+	if len(c.entries) != 1 || len(c.order) != 1 {
+		t.Errorf("%d entries left after the index changed, want 1", len(c.entries))
+	}
+}
+
+// BenchmarkBackspace types a query and deletes it again, as a user trying
+// queries out does, with and without the cache to fall back on.
+func BenchmarkBackspace(b *testing.B) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	index := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	index.setIndex(benchIndex(20000))
+
+	for _, cached := range []bool{true, false} {
+		name := "cached"
+		if !cached {
+			name = "uncached"
+		}
+		recalculate := func() {
+			if !cached {
+				// as if the index had changed
+				index.mu.Lock()
+				index.generation++
+				index.mu.Unlock()
+			}
+			index.Recalculate()
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, query := range []string{"c", "cf", "cfg", "cf", "c", ""} {
+					setQuery(query)
+					recalculate()
+				}
+			}
+		})
+	}
+}
+
+// TestMatchCacheGeneration checks that paths added or removed under an
+// unchanged query aren't hidden behind its cached matches.
+func TestMatchCacheGeneration(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	b := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	defer settle(b, runtime.NumGoroutine())
+	b.AppendFilepaths([]string{"/base/src", "/base/docs"})
+	b.Recalculate()
+	if matches := matchesOf(b); len(matches) != 2 {
+		t.Fatalf("got %v, want both paths", matches)
+	}
+
+	b.AppendFilepaths([]string{"/base/api"})
+	b.Recalculate()
+	if matches := matchesOf(b); len(matches) != 3 {
+		t.Errorf("after adding a path, got %v, want all three", matches)
+	}
+	b.RemoveFilepaths("/base/src")
+	b.Recalculate()
+	if matches := matchesOf(b); len(matches) != 2 {
+		t.Errorf("after removing a path, got %v, want two", matches)
+	}
+}
+
+// TestMatchCacheTriggers checks that editing the query, switching -scorer and
+// toggling -match-basename each miss the matches cached before the change.
+func TestMatchCacheTriggers(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	defer setQuery("")
+	setMatching := func(scorer int, base bool) {
+		search.mu.Lock()
+		search.scorer, search.matchBase = scorer, base
+		search.mu.Unlock()
+	}
+	defer setMatching(scorerIndex("flat"), false)
+	b := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	b.setIndex([]string{filepath.FromSlash("/base/ap/i"), filepath.FromSlash("/base/api")})
+
+	for _, tt := range []struct {
+		change string
+		apply  func()
+		want   int
+	}{
+		{"the query", func() { setQuery("api") }, 2},
+		{"the query", func() { setQuery("apx") }, 0},
+		{"the query", func() { setQuery("api") }, 2},
+		// only api matches within one segment
+		{"-scorer", func() { setMatching(scorerIndex("components"), false) }, 1},
+		{"-scorer", func() { setMatching(scorerIndex("flat"), false) }, 2},
+		// i is the base name of ap/i
+		{"-match-basename", func() { setMatching(scorerIndex("flat"), true) }, 1},
+		{"-match-basename", func() { setMatching(scorerIndex("flat"), false) }, 2},
+	} {
+		before := b.cacheKey()
+		tt.apply()
+		if b.cacheKey() == before {
+			t.Errorf("changing %s left the cache key %+v", tt.change, before)
+		}
+		b.Recalculate()
+		if len(b.matches) != tt.want {
+			t.Errorf("after changing %s to %q: got %v, want %d matches", tt.change, search.Value(), b.matches, tt.want)
+		}
+	}
+}
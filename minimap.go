@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/nsf/termbox-go"
+)
+
+var minimap = flag.Bool("minimap", false, "show where the matches lie in the full list in a column at the right edge")
+
+// shades runs from the fewest to the most matches per minimap cell.
+var shades = []rune{' ', '░', '▒', '▓'}
+
+// drawMinimap squeezes every row onto the results' height in the rightmost
+// column, shading each cell by the share of its rows that are matches rather
+// than -group headers, and reversing the cells under the viewport. b.mu must
+// be held.
+func (b *resultsBox) drawMinimap() {
+	w, _ := viewSize()
+	top, height := viewLayout().results, resultRows()
+	n := len(b.matches)
+	if !*minimap || height < 1 || n <= height {
+		return
+	}
+	start, end := b.visibleRange()
+	for y := 0; y < height; y++ {
+		first, last := y*n/height, (y+1)*n/height
+		var count int
+		for i := first; i < last; i++ {
+			if !b.isHeader(i) {
+				count++
+			}
+		}
+		shade := shades[0]
+		if count > 0 {
+			shade = shades[1+(count*(len(shades)-1)-1)/(last-first)]
+		}
+		var attr termbox.Attribute
+		if last > start && first < end {
+			attr = termbox.AttrReverse
+		}
+		setCell(w-1, y+top, shade, termbox.ColorDefault|attr, termbox.ColorDefault)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"runtime"
+)
+
+var oneFilesystem = flag.Bool("one-filesystem", false, "don't descend into directories on other filesystems (like find -xdev)")
+
+// baseDevice is the device holding the basepath, set by initOneFilesystem.
+var (
+	baseDevice    uint64
+	hasBaseDevice bool
+)
+
+func initOneFilesystem(basepath string) {
+	if !*oneFilesystem {
+		return
+	}
+	if info, err := os.Stat(basepath); err == nil {
+		baseDevice, hasBaseDevice = deviceID(info)
+	}
+	if !hasBaseDevice {
+		log.Printf("-one-filesystem is not supported on %s", runtime.GOOS)
+	}
+}
+
+// crossesFilesystem reports whether the directory info describes is a mount
+// point that -one-filesystem should not descend into.
+func crossesFilesystem(info os.FileInfo) bool {
+	if !hasBaseDevice {
+		return false
+	}
+	dev, ok := deviceID(info)
+	return ok && dev != baseDevice
+}
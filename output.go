@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// formatResult applies the output options to the selected path.
+func formatResult(path string) string {
+	if *dirOfSel {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			path = filepath.Dir(path)
+		}
+	}
+	if len(acceptFields.ranges) > 0 {
+		path = acceptFields.apply(search.relativePath(path))
+	}
+	if *baseOnly {
+		path = filepath.Base(path)
+	}
+	if *shellQuote {
+		path = posixQuote(path)
+	}
+	return path
+}
+
+// actOutput is where -act=fd:N writes; it is nil for -act=copy.
+var actOutput io.Writer
+
+// initAct validates -act, opening its file descriptor if it names one.
+func initAct() error {
+	if *actOn == "copy" {
+		return nil
+	}
+	if fd := strings.TrimPrefix(*actOn, "fd:"); fd != *actOn {
+		if n, err := strconv.Atoi(fd); err == nil && n >= 0 {
+			actOutput = os.NewFile(uintptr(n), "fd "+fd)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid -act %q: want copy or fd:N", *actOn)
+}
+
+// actOnSelection performs -act on the formatted path, leaving the picker open.
+func actOnSelection(path string) {
+	if actOutput == nil {
+		copySelection(formatResult(path))
+		return
+	}
+	if _, err := fmt.Fprintln(actOutput, formatResult(path)); err != nil {
+		log.Printf("-act: %v", err)
+		search.Notify("act failed")
+		return
+	}
+	search.Notify("sent")
+}
+
+// posixQuote single-quotes s unless it consists only of characters that are
+// never special to a POSIX shell.
+func posixQuote(s string) string {
+	safe := s != ""
+	for _, r := range s {
+		if !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-", r) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+var outputOrder = flag.String("order", "score", "order of -print-all and -json output: score, path (ignoring case) or mtime (newest first)")
+
+// orderOutput reorders matches, already ranked by score, for -order.
+func orderOutput(matches []string) {
+	switch *outputOrder {
+	case "path":
+		sort.SliceStable(matches, func(i, j int) bool {
+			li, lj := strings.ToLower(matches[i]), strings.ToLower(matches[j])
+			if li != lj {
+				return li < lj
+			}
+			return matches[i] < matches[j]
+		})
+	case "mtime":
+		// as the walk found them, so ties and -stdin lines keep their rank
+		sort.SliceStable(matches, func(i, j int) bool {
+			return mtimes.Get(matches[i]).After(mtimes.Get(matches[j]))
+		})
+	}
+}
+
+// writeLines writes matches, in rank order, formatted as the selection would
+// be, each ended by a newline or with -print0 a NUL.
+func writeLines(w io.Writer, matches []string) error {
+	end := "\n"
+	if *print0 {
+		end = "\x00"
+	}
+	bw := bufio.NewWriter(w)
+	for _, path := range matches {
+		if _, err := bw.WriteString(formatResult(path) + end); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+type jsonMatch struct {
+	Path    string  `json:"path"`
+	Display string  `json:"display"`
+	Score   float32 `json:"score"`
+}
+
+// writeJSON writes matches, in rank order, as a single JSON array.
+func writeJSON(w io.Writer, matches []string) error {
+	out := make([]jsonMatch, 0, len(matches))
+	for _, path := range matches {
+		out = append(out, jsonMatch{
+			Path:    path,
+			Display: search.displayPath(path),
+			Score:   search.Score(path),
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
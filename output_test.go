@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+func TestDirOfSelection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(dirOf bool) { *dirOfSel = dirOf }(*dirOfSel)
+	*dirOfSel = true
+	if got := formatResult(file); got != dir {
+		t.Errorf("a file printed as %s, want its directory %s", got, dir)
+	}
+	if got := formatResult(dir); got != dir {
+		t.Errorf("a directory printed as %s, want itself", got)
+	}
+
+	*dirOfSel = false
+	if got := formatResult(file); got != file {
+		t.Errorf("without -dir-of-selection a file printed as %s", got)
+	}
+}
+
+func TestBasenameOutput(t *testing.T) {
+	defer func(base bool) { *baseOnly = base }(*baseOnly)
+	*baseOnly = true
+	if got := formatResult(filepath.FromSlash("/base/src/api")); got != "api" {
+		t.Errorf("printed %q, want api", got)
+	}
+
+	b, ok := lookupBinding(termbox.Event{Ch: 'n', Mod: termbox.ModAlt})
+	if !ok || b.ev != EventCopyBasename {
+		t.Errorf("Alt-n is bound to %v, want EventCopyBasename", b.ev)
+	}
+}
+
+func TestWriteLines(t *testing.T) {
+	matches := []string{"/base/src", "/base/docs"}
+	var buf bytes.Buffer
+	if err := writeLines(&buf, matches); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "/base/src\n/base/docs\n"; got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+
+	defer func(nul bool) { *print0 = nul }(*print0)
+	*print0 = true
+	buf.Reset()
+	writeLines(&buf, matches)
+	if got, want := buf.String(), "/base/src\x00/base/docs\x00"; got != want {
+		t.Errorf("with -print0, wrote %q, want %q", got, want)
+	}
+}
+
+func TestOrderOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(order string, files, self bool) {
+		*outputOrder, *includeFiles, *noSelf = order, files, self
+	}(*outputOrder, *includeFiles, *noSelf)
+	defer func(m *mtimeMap) { mtimes = m }(mtimes)
+	mtimes = &mtimeMap{mtimes: map[string]time.Time{}}
+
+	// a -files file among the directories
+	var paths []string
+	for i, name := range []string{"b", "A", "c.go"} {
+		path := filepath.Join(dir, name)
+		if filepath.Ext(name) == "" {
+			os.Mkdir(path, 0755)
+		} else {
+			ioutil.WriteFile(path, nil, 0644)
+		}
+		mtime := time.Now().Add(time.Duration(i) * time.Hour)
+		os.Chtimes(path, mtime, mtime)
+		paths = append(paths, path)
+	}
+	*outputOrder, *includeFiles, *noSelf = "mtime", true, true
+	if indexed := indexAll(dir); len(indexed) != len(paths) {
+		t.Fatalf("indexed %v, want %v", indexed, paths)
+	}
+	// -order mtime sorts as the walk found them, not as they are now
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(paths[2], past, past)
+
+	for order, want := range map[string][]string{
+		"score": {"b", "A", "c.go"},
+		"path":  {"A", "b", "c.go"},
+		"mtime": {"c.go", "A", "b"},
+	} {
+		*outputOrder = order
+		matches := append([]string(nil), paths...)
+		orderOutput(matches)
+		for i := range want {
+			want[i] = filepath.Join(dir, want[i])
+		}
+		if !reflect.DeepEqual(matches, want) {
+			t.Errorf("-order %s: got %v, want %v", order, matches, want)
+		}
+	}
+}
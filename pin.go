@@ -0,0 +1,79 @@
+package main
+
+import "log"
+
+// TogglePin pins the selected path to the top of the list for any query it
+// matches, or unpins it, saving the pins for this basepath.
+func (b *resultsBox) TogglePin() {
+	b.mu.Lock()
+	if b.selected < 0 || b.selected >= len(b.matches) || b.isHeader(b.selected) {
+		b.mu.Unlock()
+		return
+	}
+	path := b.matches[b.selected]
+	if b.pinned == nil {
+		b.pinned = map[string]bool{}
+	}
+	pinned := !b.pinned[path]
+	if pinned {
+		b.pinned[path] = true
+	} else {
+		delete(b.pinned, path)
+	}
+	b.mu.Unlock()
+
+	savePin(path, pinned)
+	if pinned {
+		search.Notify("pinned")
+	} else {
+		search.Notify("unpinned")
+	}
+	b.Recalculate()
+}
+
+// isPinned reports whether path is pinned. b.mu must be held.
+func (b *resultsBox) isPinned(path string) bool {
+	return b.pinned[path]
+}
+
+// pinnedFirst moves the pinned paths among matches to the front, keeping the
+// order within each part. matches may be cached, so a new slice is returned.
+func (b *resultsBox) pinnedFirst(matches []string) []string {
+	if len(b.pinned) == 0 {
+		return matches
+	}
+	sorted := make([]string, 0, len(matches))
+	for _, path := range matches {
+		if b.pinned[path] {
+			sorted = append(sorted, path)
+		}
+	}
+	for _, path := range matches {
+		if !b.pinned[path] {
+			sorted = append(sorted, path)
+		}
+	}
+	return sorted
+}
+
+func savePin(path string, pinned bool) {
+	st, err := loadState()
+	if err != nil {
+		log.Printf("loading state: %v", err)
+		return
+	}
+	ps := st.project(search.basepath)
+	pins := ps.Pinned[:0:0]
+	for _, p := range ps.Pinned {
+		if p != path {
+			pins = append(pins, p)
+		}
+	}
+	if pinned {
+		pins = append(pins, path)
+	}
+	ps.Pinned = pins
+	if err := st.save(); err != nil {
+		log.Printf("saving state: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"sort"
+	"sync"
+	"time"
+)
+
+var recent = flag.Bool("recent", false, "list paths most recently modified first, ignoring relevance; a query still filters them")
+
+// mtimeMap records when each indexed path was last modified, as the walk
+// found it, for -recent and -order mtime.
+type mtimeMap struct {
+	mu     sync.Mutex
+	mtimes map[string]time.Time
+}
+
+var mtimes = &mtimeMap{mtimes: map[string]time.Time{}}
+
+// keepMtimes reports whether the walk should record mtimes.
+func keepMtimes() bool {
+	return *recent || *outputOrder == "mtime"
+}
+
+func (m *mtimeMap) Set(path string, mtime time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mtimes[path] = mtime
+}
+
+// Get returns the zero time for paths the walk didn't record, like the
+// basepath itself, sorting them last.
+func (m *mtimeMap) Get(path string) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.mtimes[path]
+}
+
+// sortByMtime sorts paths newest first, then lexically.
+func sortByMtime(paths []string) {
+	sort.Slice(paths, func(i, j int) bool {
+		ti, tj := mtimes.Get(paths[i]), mtimes.Get(paths[j])
+		if ti.Equal(tj) {
+			return paths[i] < paths[j]
+		}
+		return ti.After(tj)
+	})
+}
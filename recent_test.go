@@ -0,0 +1,56 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecent(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	defer func(on bool) { *recent = on }(*recent)
+	defer func(m *mtimeMap) { mtimes = m }(mtimes)
+	defer setQuery("")
+	*recent = true
+	mtimes = &mtimeMap{mtimes: map[string]time.Time{}}
+
+	now := time.Now()
+	paths := []string{"/base", "/base/api", "/base/lib/apis", "/base/old/a", "/base/zap"}
+	for path, age := range map[string]time.Duration{
+		"/base/api":      2 * time.Hour,
+		"/base/lib/apis": time.Hour,
+		"/base/old/a":    24 * time.Hour,
+		"/base/zap":      time.Hour,
+	} {
+		mtimes.Set(filepath.FromSlash(path), now.Add(-age))
+	}
+	for i := range paths {
+		paths[i] = filepath.FromSlash(paths[i])
+	}
+
+	// newest first, ties lexically, and the unrecorded basepath last
+	sorted := append([]string(nil), paths...)
+	sortByScore(sorted, testMatcher("", false))
+	want := []string{"/base/lib/apis", "/base/zap", "/base/api", "/base/old/a", "/base"}
+	for i := range want {
+		want[i] = filepath.FromSlash(want[i])
+	}
+	if !reflect.DeepEqual(sorted, want) {
+		t.Errorf("got %v, want %v", sorted, want)
+	}
+
+	// a query filters without reranking, and the newest match is selected
+	b := &resultsBox{preselect: -1, initDone: make(chan struct{})}
+	b.setIndex(sorted)
+	setQuery("api")
+	b.Recalculate()
+	b.SelectBestMatch()
+	if want := []string{want[0], want[2]}; !reflect.DeepEqual(b.matches, want) {
+		t.Errorf("api: got %v, want %v", b.matches, want)
+	}
+	if got, _ := b.Selection(); got != want[0] {
+		t.Errorf("selected %s, want the newest match %s", got, want[0])
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+var fileManagers = map[string]string{
+	"darwin":  "open",
+	"linux":   "xdg-open",
+	"freebsd": "xdg-open",
+	"openbsd": "xdg-open",
+	"netbsd":  "xdg-open",
+	"windows": "explorer",
+}
+
+// revealPath opens path in the OS file manager. Files are revealed by
+// opening the directory that contains them.
+func revealPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		path = filepath.Dir(path)
+	}
+
+	opener, ok := fileManagers[runtime.GOOS]
+	if !ok {
+		return fmt.Errorf("don't know how to open a file manager on %s", runtime.GOOS)
+	}
+	if _, err := exec.LookPath(opener); err != nil {
+		return fmt.Errorf("no file manager opener found: %v", err)
+	}
+	return exec.Command(opener, path).Start()
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestFindReveal(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	b := &resultsBox{matches: []string{"/base/api", "/base/src/api", "/base/docs/myapi", "/base/src"}}
+	for reveal, want := range map[string]int{
+		"src/api":       1,
+		"/base/src/api": 1,
+		"./src/":        3,
+		// the best ranked match ending with it, at a segment boundary
+		"api":     0,
+		"myapi":   2,
+		"missing": -1,
+	} {
+		b.revealPath = reveal
+		i, ok := b.findReveal()
+		if !ok {
+			i = -1
+		}
+		if i != want {
+			t.Errorf("-reveal-path %s found row %d, want %d", reveal, i, want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	scorerName      = flag.String("scorer", "flat", "how terms match: flat, each as a subsequence of the whole path, or components, each within one path segment, ranking later segments higher (F2 cycles)")
+	componentWeight = flag.Float64("component-weight", 0.5, "with -scorer=components, the extra cost of a term for each segment between it and the last")
+)
+
+// scorers lists the valid -scorer values, in the order F2 cycles through them.
+var scorers = []string{"flat", "components"}
+
+// scorerIndex finds name in scorers, or returns -1.
+func scorerIndex(name string) int {
+	for i, s := range scorers {
+		if s == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// CycleScorer switches to the next scorer, wrapping around after the last.
+func (b *searchBox) CycleScorer() {
+	b.mu.Lock()
+	b.scorer = (b.scorer + 1) % len(scorers)
+	b.mu.Unlock()
+
+	go func() {
+		results.Recalculate()
+		results.SelectBestMatch()
+	}()
+}
+
+func (b *searchBox) Scorer() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return scorers[b.scorer]
+}
+
+// componentCost matches term within a single segment of lower, which must
+// already be lowercase, choosing the cheapest segment once each is weighted by
+// how far it lies from the last. Terms may match segments in any order, so api
+// user finds both api/users and users/api, but the one with users as its base
+// name ranks higher for that query.
+func componentCost(lower, term string) (float32, bool) {
+	segments := strings.Split(lower, string(filepath.Separator))
+	var best float32
+	var found bool
+	for i, segment := range segments {
+		cost, ok := termCost(segment, term)
+		if !ok {
+			continue
+		}
+		cost *= 1 + float32(*componentWeight)*float32(len(segments)-1-i)
+		if !found || cost < best {
+			best, found = cost, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestComponentCost(t *testing.T) {
+	defer func(weight float64) { *componentWeight = weight }(*componentWeight)
+	*componentWeight = 0.5
+
+	// a term matches within one segment, the cheaper the later it is
+	if cost, ok := componentCost(filepath.FromSlash("users/api"), "api"); !ok || cost != 3 {
+		t.Errorf("api in users/api: cost %v, %v; want 3", cost, ok)
+	}
+	if cost, ok := componentCost(filepath.FromSlash("api/users"), "api"); !ok || cost != 4.5 {
+		t.Errorf("api in api/users: cost %v, %v; want 4.5", cost, ok)
+	}
+	// and never across a separator
+	if _, ok := componentCost(filepath.FromSlash("ap/i"), "api"); ok {
+		t.Error("api matched across ap/i")
+	}
+}
+
+func TestComponentsScorer(t *testing.T) {
+	m := testMatcher("api user", false)
+	m.scorer = scorerIndex("components")
+	usersAPI, apiUsers := m.scoreText(filepath.FromSlash("users/api")), m.scoreText(filepath.FromSlash("api/users"))
+	if usersAPI == 0 || apiUsers == 0 {
+		t.Fatalf("terms in either order should match: scored %v and %v", usersAPI, apiUsers)
+	}
+	// the term that names the base name counts for more
+	m = testMatcher("users", false)
+	m.scorer = scorerIndex("components")
+	if deep, shallow := m.scoreText(filepath.FromSlash("api/users")), m.scoreText(filepath.FromSlash("users/api")); deep <= shallow {
+		t.Errorf("users as the base name scored %v, not above %v", deep, shallow)
+	}
+	// a term naming several segments still matches across them
+	m = testMatcher("api/u", false)
+	m.scorer = scorerIndex("components")
+	if m.scoreText(filepath.FromSlash("api/users")) == 0 {
+		t.Error("api/u didn't match api/users")
+	}
+}
+
+func TestCycleScorer(t *testing.T) {
+	search.basepath = "/base"
+	defer func() { search.basepath = "" }()
+	setScorer := func(scorer int) {
+		search.mu.Lock()
+		search.scorer = scorer
+		search.mu.Unlock()
+	}
+	defer setScorer(scorerIndex(search.Scorer()))
+	defer setQuery("")
+	results.mu.Lock()
+	results.setIndex([]string{filepath.FromSlash("/base/ap/i"), filepath.FromSlash("/base/api")})
+	results.mu.Unlock()
+	defer func() {
+		results.mu.Lock()
+		results.setIndex(nil)
+		results.matches = nil
+		results.mu.Unlock()
+	}()
+
+	setScorer(scorerIndex("flat"))
+	setQuery("api")
+	search.CycleScorer()
+	if got := search.Scorer(); got != "components" {
+		t.Fatalf("F2 switched to %s, want components", got)
+	}
+	if got, want := bestChosen(t), []string{filepath.FromSlash("/base/api")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("components: got %v, want %v", got, want)
+	}
+
+	// past the last scorer back to the first, with its own cached matches
+	search.CycleScorer()
+	if got := search.Scorer(); got != "flat" {
+		t.Fatalf("F2 switched to %s, want flat", got)
+	}
+	if got := bestChosen(t); len(got) != 2 {
+		t.Errorf("flat: got %v, want both paths", got)
+	}
+}
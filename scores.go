@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+
+	"github.com/nsf/termbox-go"
+)
+
+var showScores = flag.Bool("show-scores", false, "show each result's score in a column at the right (Alt-# toggles)")
+
+// ToggleScores shows or hides the score column.
+func (b *resultsBox) ToggleScores() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.scores = !b.scores
+}
+
+// formatScore keeps three significant figures, which is enough to tell
+// neighbouring results apart.
+func formatScore(score float32) string {
+	return strconv.FormatFloat(float64(score), 'g', 3, 32)
+}
+
+// rightColumns is how many columns at the right edge belong to the score
+// column and the minimap, so the paths stop short of them. b.mu must be held.
+func (b *resultsBox) rightColumns() int {
+	var width int
+	if *minimap && len(b.matches) > resultRows() {
+		width++
+	}
+	if b.scores {
+		if n := b.scoresWidth(); n > 0 {
+			width += n + 1
+		}
+	}
+	return width
+}
+
+// scoresWidth is the width of the widest visible score. b.mu must be held.
+func (b *resultsBox) scoresWidth() int {
+	var width int
+	start, end := b.visibleRange()
+	for i := start; i < end; i++ {
+		if b.isHeader(i) {
+			continue
+		}
+		if n := len(formatScore(search.Score(b.matches[i]))); n > width {
+			width = n
+		}
+	}
+	return width
+}
+
+// drawScores right-aligns the score of each visible row, other than -group
+// headers, just left of the minimap. b.mu must be held.
+func (b *resultsBox) drawScores() {
+	if !b.scores {
+		return
+	}
+	w, _ := viewSize()
+	end := w
+	if *minimap && len(b.matches) > resultRows() {
+		end--
+	}
+	top := viewLayout().results
+	start, stop := b.visibleRange()
+	for i := start; i < stop; i++ {
+		if b.isHeader(i) {
+			continue
+		}
+		score := formatScore(search.Score(b.matches[i]))
+		drawText(end-len(score), i-start+top, end, score, termbox.ColorDefault, termbox.ColorDefault)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// inlineScreen draws a -height or -inline view straight onto the terminal's
+// main screen, in rows reserved below the cursor, leaving everything above it
+// and the scrollback as they were. termbox would otherwise take over the whole
+// alternate screen; it is still used to read the keyboard and mouse.
+type inlineScreen struct {
+	out *os.File
+	// top is the terminal row the view starts on
+	top int
+	// w and h are the terminal's size as of the last Clear
+	w, h int
+	rows int
+
+	cells            []termbox.Cell
+	cursorX, cursorY int
+}
+
+// screen is non-nil while an inline view is on the terminal.
+var screen *inlineScreen
+
+// inlineView reports whether the view is drawn below the cursor rather than
+// over the whole screen.
+func inlineView() bool {
+	return heightRows != 0 || *inline
+}
+
+// openInlineScreen reserves rows for the view below row, where the cursor
+// was before termbox started, scrolling the screen up if there isn't room.
+// If the row couldn't be found, the view goes at the bottom.
+func openInlineScreen(row int, rowErr error) (*inlineScreen, error) {
+	out, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	s := &inlineScreen{out: out, cursorX: -1, cursorY: -1}
+	s.resize()
+
+	// back from termbox's alternate screen, which restores the cursor
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[?1049l")
+	if rowErr != nil {
+		log.Printf("placing the view at the bottom: %v", rowErr)
+		buf.WriteString("\x1b[" + strconv.Itoa(s.h) + ";1H")
+		buf.WriteString(strings.Repeat("\n", s.rows))
+		s.top = s.h - s.rows
+	} else {
+		buf.WriteString("\r" + strings.Repeat("\n", s.rows-1))
+		bottom := row + s.rows - 1
+		if bottom > s.h-1 {
+			bottom = s.h - 1
+		}
+		s.top = bottom - (s.rows - 1)
+	}
+	if _, err := out.Write(buf.Bytes()); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// resize catches up with the terminal's size, keeping the view on screen.
+func (s *inlineScreen) resize() {
+	w, h, err := ttySize(s.out.Fd())
+	if err != nil {
+		w, h = termbox.Size()
+	}
+	s.w, s.h = w, h
+	s.rows = viewRows(h)
+	if s.top+s.rows > h {
+		s.top = h - s.rows
+	}
+	if s.top < 0 {
+		s.top = 0
+	}
+}
+
+// Clear blanks the view, ready to draw the next frame.
+func (s *inlineScreen) Clear() {
+	s.resize()
+	w, _ := termSize()
+	if n := w * s.rows; len(s.cells) != n {
+		s.cells = make([]termbox.Cell, n)
+	}
+	for i := range s.cells {
+		s.cells[i] = termbox.Cell{Ch: ' '}
+	}
+	s.cursorX, s.cursorY = -1, -1
+}
+
+// SetCell sets the cell at x, y within the view.
+func (s *inlineScreen) SetCell(x, y int, r rune, fg, bg termbox.Attribute) {
+	w, _ := termSize()
+	if x < 0 || x >= w || y < 0 || y >= s.rows {
+		return
+	}
+	s.cells[y*w+x] = termbox.Cell{Ch: r, Fg: fg, Bg: bg}
+}
+
+// SetCursor shows the cursor at x, y within the view, or hides it for
+// negative coordinates.
+func (s *inlineScreen) SetCursor(x, y int) {
+	s.cursorX, s.cursorY = x, y
+}
+
+// Flush writes the whole view, which is never more than a few rows, so there
+// is no need to track what changed since the last frame.
+func (s *inlineScreen) Flush() error {
+	w, _ := termSize()
+	if w <= 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[?25l")
+	for y := 0; y < s.rows && y < len(s.cells)/w; y++ {
+		buf.WriteString("\x1b[" + strconv.Itoa(s.top+y+1) + ";1H")
+		var last termbox.Cell
+		for x := 0; x < w && x < s.w; x++ {
+			c := s.cells[y*w+x]
+			if x == 0 || c.Fg != last.Fg || c.Bg != last.Bg {
+				buf.WriteString(sgr(c.Fg, c.Bg))
+			}
+			last = c
+			if c.Ch < ' ' {
+				c.Ch = ' '
+			}
+			buf.WriteRune(c.Ch)
+			// drawText leaves the cell after a wide rune alone
+			if cellWidth(c.Ch) == 2 {
+				x++
+			}
+		}
+		buf.WriteString("\x1b[m\x1b[K")
+	}
+	if s.cursorX >= 0 && s.cursorY >= 0 {
+		buf.WriteString("\x1b[" + strconv.Itoa(s.top+s.cursorY+1) + ";" + strconv.Itoa(s.cursorX+1) + "H\x1b[?25h")
+	}
+	_, err := s.out.Write(buf.Bytes())
+	return err
+}
+
+// Close blanks the view and leaves the cursor where it began, for whatever
+// runs next to carry on from there. termbox.Close clears and leaves the
+// alternate screen, so it's re-entered first to keep that away from the
+// main one.
+func (s *inlineScreen) Close() {
+	s.out.WriteString("\x1b[m\x1b[" + strconv.Itoa(s.top+1) + ";1H\x1b[J\x1b[?1049h")
+	s.out.Close()
+}
+
+// sgr selects the colors and attributes termbox would for fg and bg.
+func sgr(fg, bg termbox.Attribute) string {
+	codes := []string{"0"}
+	if fg&termbox.AttrBold != 0 {
+		codes = append(codes, "1")
+	}
+	if fg&termbox.AttrUnderline != 0 {
+		codes = append(codes, "4")
+	}
+	if (fg|bg)&termbox.AttrReverse != 0 {
+		codes = append(codes, "7")
+	}
+	if c := fg & 0x0f; c != termbox.ColorDefault {
+		codes = append(codes, strconv.Itoa(30+int(c)-1))
+	}
+	if c := bg & 0x0f; c != termbox.ColorDefault {
+		codes = append(codes, strconv.Itoa(40+int(c)-1))
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	"github.com/nsf/termbox-go"
+)
+
+// initTerminal starts termbox in the input mode nav expects, and makes room
+// below the cursor for a -height or -inline view.
+func initTerminal() error {
+	var row int
+	var rowErr error
+	if inlineView() {
+		// asked before termbox starts reading the terminal itself
+		row, rowErr = cursorRow()
+	}
+	if err := termbox.Init(); err != nil {
+		return err
+	}
+	termbox.SetInputMode(termbox.InputAlt | termbox.InputMouse)
+	if inlineView() {
+		s, err := openInlineScreen(row, rowErr)
+		if err != nil {
+			termbox.Close()
+			return err
+		}
+		screen = s
+	}
+	return nil
+}
+
+// closeTerminal undoes initTerminal.
+func closeTerminal() {
+	if screen != nil {
+		screen.Close()
+		screen = nil
+	}
+	termbox.Close()
+}
+
+// openShell suspends the picker, runs the user's shell in path (or the
+// directory containing it), and resumes with the query and selection as they
+// were once the shell exits. Only a failure to resume is returned.
+func openShell(path string) error {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		path = filepath.Dir(path)
+	}
+	shell := os.Getenv("SHELL")
+	if runtime.GOOS == "windows" {
+		shell = os.Getenv("COMSPEC")
+	}
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+
+	cmd := exec.Command(shell)
+	cmd.Dir = path
+	return suspend(cmd, func(err error) {
+		log.Printf("shell: %v", err)
+	})
+}
+
+// suspend closes termbox, runs cmd on the terminal, passes any error from cmd
+// to fail, and resumes. Every draw is held off until termbox is back. Only a
+// failure to resume is returned.
+func suspend(cmd *exec.Cmd, fail func(error)) error {
+	drawMutex.Lock()
+	defer drawMutex.Unlock()
+
+	closeTerminal()
+
+	// stdout is often a pipe to whoever is waiting for nav's answer, so the
+	// command gets the terminal itself
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stderr, os.Stderr
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	}
+	// the command shares nav's process group, so Ctrl-C and Ctrl-\ reach nav
+	// too; catching them for as long as it runs leaves them to the command,
+	// which starts with the default handling of caught signals
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGQUIT)
+	err := cmd.Run()
+	signal.Stop(interrupts)
+	if err != nil {
+		fail(err)
+	}
+
+	return initTerminal()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// openPty opens a pseudo-terminal, returning its master and slave ends.
+func openPty(t *testing.T) (master, slave *os.File) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skip("no pseudo-terminals:", err)
+	}
+	var n, unlock uint32
+	if err := ioctl(int(master.Fd()), syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioctl(int(master.Fd()), syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		t.Fatal(err)
+	}
+	slave, err = os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := struct{ rows, cols, xpixels, ypixels uint16 }{rows: 24, cols: 80}
+	if err := ioctl(int(slave.Fd()), syscall.TIOCSWINSZ, unsafe.Pointer(&size)); err != nil {
+		t.Fatal(err)
+	}
+	return master, slave
+}
+
+// runNav starts nav in dir on the terminal slave is open on, returning what
+// it writes there as it goes. Its answer goes to stdout, or the terminal if
+// that is nil.
+func runNav(t *testing.T, master, slave *os.File, stdout io.Writer, dir, args string) (*exec.Cmd, func() []byte) {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(),
+		"NAV_TEST_MAIN="+args+" "+dir,
+		"TERM=xterm",
+		"XDG_CONFIG_HOME="+dir,
+		"XDG_STATE_HOME="+dir,
+	)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var out []byte
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := master.Read(buf)
+			mu.Lock()
+			out = append(out, buf[:n]...)
+			mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return cmd, func() []byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]byte(nil), out...)
+	}
+}
+
+// TestSignalRestoresTerminal sends nav SIGTERM and SIGHUP while the picker is
+// up, as killing its parent or closing its terminal would, and checks that it
+// exits by the signal's status with the terminal back in its original mode.
+func TestSignalRestoresTerminal(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs nav in a child process")
+	}
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, sig := range []syscall.Signal{syscall.SIGTERM, syscall.SIGHUP} {
+		master, slave := openPty(t)
+		var orig syscall.Termios
+		if err := ioctl(int(slave.Fd()), getTermios, unsafe.Pointer(&orig)); err != nil {
+			t.Fatal(err)
+		}
+		cmd, output := runNav(t, master, slave, nil, dir, "")
+
+		// the alternate screen is entered once the picker is up
+		for start := time.Now(); !bytes.Contains(output(), []byte("\x1b[?1049h")); time.Sleep(10 * time.Millisecond) {
+			if time.Since(start) > 5*time.Second {
+				cmd.Process.Kill()
+				t.Fatalf("%v: the picker never started: %q", sig, output())
+			}
+		}
+		drawn := len(output())
+		cmd.Process.Signal(sig)
+
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+		select {
+		case err := <-done:
+			exit, ok := err.(*exec.ExitError)
+			if want := 128 + int(sig); !ok || exit.ExitCode() != want {
+				t.Errorf("%v: exited with %v, want status %d", sig, err, want)
+			}
+		case <-time.After(5 * time.Second):
+			cmd.Process.Kill()
+			t.Fatalf("%v: nav didn't exit", sig)
+		}
+
+		var after syscall.Termios
+		if err := ioctl(int(slave.Fd()), getTermios, unsafe.Pointer(&after)); err != nil {
+			t.Fatal(err)
+		}
+		if after.Lflag != orig.Lflag || after.Iflag != orig.Iflag || after.Oflag != orig.Oflag {
+			t.Errorf("%v: the terminal was left in raw mode", sig)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if !bytes.Contains(output()[drawn:], []byte("\x1b[?1049l")) {
+			t.Errorf("%v: the alternate screen was never left", sig)
+		}
+		slave.Close()
+		master.Close()
+	}
+}
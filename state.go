@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const maxHistory = 50
+
+// state is persisted between runs, keyed by basepath.
+type state struct {
+	Projects map[string]*projectState `json:"projects"`
+}
+
+type projectState struct {
+	History []string `json:"history,omitempty"`
+	// Last is the path most recently selected.
+	Last string `json:"last,omitempty"`
+	// Pinned paths are listed first whenever they match.
+	Pinned []string `json:"pinned,omitempty"`
+}
+
+func statePath() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "nav", "state.json"), nil
+}
+
+// loadState reads the state file. A missing file yields an empty state.
+func loadState() (*state, error) {
+	st := &state{Projects: map[string]*projectState{}}
+	path, err := statePath()
+	if err != nil {
+		return st, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return st, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return st, err
+	}
+	if st.Projects == nil {
+		st.Projects = map[string]*projectState{}
+	}
+	return st, nil
+}
+
+func (st *state) save() error {
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// write then rename so a concurrent nav never reads a partial file
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (st *state) project(basepath string) *projectState {
+	ps, ok := st.Projects[basepath]
+	if !ok {
+		ps = &projectState{}
+		st.Projects[basepath] = ps
+	}
+	return ps
+}
+
+// addHistory appends query as the most recent entry, dropping any earlier
+// duplicate and the oldest entries beyond maxHistory.
+func (ps *projectState) addHistory(query string) {
+	if query == "" {
+		return
+	}
+	history := ps.History[:0:0]
+	for _, q := range ps.History {
+		if q != query {
+			history = append(history, q)
+		}
+	}
+	history = append(history, query)
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	ps.History = history
+}
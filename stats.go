@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var showStats = flag.Bool("stats", false, "print walk statistics to stderr on exit")
+
+// walkStats counts what the walk has seen, for -stats.
+type walkStats struct {
+	dirs  int64 // read with atomic
+	files int64 // read with atomic
+
+	mu          sync.Mutex
+	start       time.Time
+	firstResult time.Duration
+	walked      time.Duration
+}
+
+var stats = &walkStats{start: time.Now()}
+
+func (s *walkStats) CountDir() {
+	atomic.AddInt64(&s.dirs, 1)
+}
+
+func (s *walkStats) CountFile() {
+	atomic.AddInt64(&s.files, 1)
+}
+
+// FirstResult records when the first batch of paths was found.
+func (s *walkStats) FirstResult() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.firstResult == 0 {
+		s.firstResult = time.Since(s.start)
+	}
+}
+
+// WalkDone records when the initial walk finished.
+func (s *walkStats) WalkDone() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.walked == 0 {
+		s.walked = time.Since(s.start)
+	}
+}
+
+// Print writes the summary, given how many paths ended up in the index.
+func (s *walkStats) Print(w io.Writer, indexed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "nav: %d directories walked, %d files seen, %d paths indexed\n",
+		atomic.LoadInt64(&s.dirs), atomic.LoadInt64(&s.files), indexed)
+	if s.firstResult > 0 {
+		fmt.Fprintf(w, "nav: first result after %v\n", s.firstResult)
+	}
+	if s.walked > 0 {
+		fmt.Fprintf(w, "nav: walk took %v\n", s.walked)
+	} else {
+		fmt.Fprintf(w, "nav: walk unfinished after %v\n", time.Since(s.start))
+	}
+}
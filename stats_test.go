@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "a", "b"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "a", "main.go"), nil, 0644)
+
+	defer func(s *walkStats) { stats = s }(stats)
+	stats = &walkStats{}
+	var buf bytes.Buffer
+	stats.Print(&buf, 0)
+	if !strings.Contains(buf.String(), "walk unfinished") {
+		t.Errorf("before the walk, printed %q", buf.String())
+	}
+
+	paths := indexAll(dir)
+	stats.WalkDone()
+	buf.Reset()
+	stats.Print(&buf, len(paths))
+	out := buf.String()
+	for _, want := range []string{"3 directories walked, 1 files seen, 3 paths indexed", "first result after", "walk took"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("printed %q, want %q in it", out, want)
+		}
+	}
+}
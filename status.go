@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nsf/termbox-go"
+)
+
+var hideStatus = flag.Bool("no-status", false, "hide the status row with the options and match count, giving it to the results")
+
+// layout places the boxes beneath the search box.
+type layout struct {
+	status  int // the status row, or -1 when -no-status hides it
+	results int // the first row of results
+}
+
+func viewLayout() layout {
+	if *hideStatus {
+		return layout{status: -1, results: 3}
+	}
+	return layout{status: 3, results: 4}
+}
+
+// resultRows is how many results fit in the view.
+func resultRows() int {
+	_, h := viewSize()
+	return h - viewLayout().results
+}
+
+// statusBox shows the match count, a spinner while indexing, and the options
+// that change what matches and how, on a row of its own.
+type statusBox struct {
+	frame int
+
+	mu sync.Mutex
+}
+
+var status = &statusBox{}
+
+var spinner = []rune(`|/-\`)
+
+// Counts returns how many paths match the query and how many are indexed, and
+// whether the walk, and scoring left over from Recalculate, are still going.
+func (b *resultsBox) Counts() (matched, total int, walking, scoring bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := range b.matches {
+		if !b.isHeader(i) {
+			matched++
+		}
+	}
+	return matched, len(b.filepaths), !b.walkDone, b.scoring != nil
+}
+
+// statusOptions lists the options that change what matches and how.
+func statusOptions() []string {
+	parts := []string{"fuzzy", "ignore case"}
+	if scorer := search.Scorer(); scorer != "flat" {
+		parts = append(parts, scorer)
+	}
+	if *noAccents {
+		parts = append(parts, "ignore accents")
+	}
+	if search.MatchBase() {
+		parts = append(parts, "basename")
+	}
+	if results.Inverted() {
+		parts = append(parts, "inverted")
+	}
+	if results.DirsOnly() {
+		parts = append(parts, "dirs only")
+	}
+	if search.Absolute() {
+		parts = append(parts, "absolute")
+	}
+	if *recent {
+		parts = append(parts, "recent first")
+	} else if *sortMode != "score" {
+		parts = append(parts, "sort "+*sortMode)
+	}
+	switch {
+	case *treeView:
+		parts = append(parts, "tree")
+	case *groupView:
+		parts = append(parts, "group")
+	}
+	return parts
+}
+
+// Draw puts the count on the left and the options on the right, dropping the
+// options first when the row is too narrow. It must not be called with
+// results.mu held.
+func (b *statusBox) Draw() {
+	row := viewLayout().status
+	if row < 0 {
+		return
+	}
+	matched, total, walking, scoring := results.Counts()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := fmt.Sprintf("%d/%d", matched, total)
+	if walking || scoring {
+		b.frame = (b.frame + 1) % len(spinner)
+		count += " " + string(spinner[b.frame])
+	}
+	// until the walk ends, later paths may still outrank those listed
+	if walking {
+		count += " indexing"
+	}
+	options := strings.Join(statusOptions(), " · ")
+
+	w, _ := viewSize()
+	end := drawText(2, row, w, count, termbox.ColorDefault, termbox.ColorDefault)
+	if start := w - 1 - textWidth([]rune(options)); start > end+1 {
+		drawText(start, row, w, options, termbox.ColorDefault, termbox.ColorDefault)
+	}
+}
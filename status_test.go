@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatusIndexing(t *testing.T) {
+	setWalkDone := func(done bool) {
+		results.mu.Lock()
+		results.walkDone = done
+		results.mu.Unlock()
+	}
+	results.mu.Lock()
+	walkDone := results.walkDone
+	results.mu.Unlock()
+	defer setWalkDone(walkDone)
+
+	setWalkDone(false)
+	clearScreen()
+	status.Draw()
+	if row := screenRow(viewLayout().status); !strings.Contains(row, " indexing") {
+		t.Errorf("status row %q doesn't say the walk is still going", row)
+	}
+
+	setWalkDone(true)
+	clearScreen()
+	status.Draw()
+	row := screenRow(viewLayout().status)
+	if !strings.HasPrefix(row, "  0/0 ") || strings.Contains(row, "indexing") {
+		t.Errorf("status row %q, want the bare count once the walk is done", row)
+	}
+}
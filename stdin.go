@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"time"
+)
+
+var (
+	fromStdin = flag.Bool("stdin", false, "pick from the lines read on stdin, listing them as they arrive, instead of walking the basepath")
+	fromFile  = flag.String("from", "", "pick from the lines of this file, such as saved find output, instead of walking the basepath")
+)
+
+// readingList reports whether the candidates are read from -stdin or -from
+// rather than found by walking the basepath.
+func readingList() bool {
+	return *fromStdin || *fromFile != ""
+}
+
+// initFrom checks that -from can be read before the terminal is taken over.
+func initFrom() error {
+	if *fromFile == "" {
+		return nil
+	}
+	if *fromStdin {
+		return errors.New("-stdin and -from can't be used together")
+	}
+	f, err := os.Open(*fromFile)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// readList sends the candidates from -stdin or -from on batches, closing
+// batches at the end of input. Unlike stdin, -from can be read again.
+func readList(batches chan<- []string) {
+	if *fromStdin {
+		readCandidates(os.Stdin, batches)
+		return
+	}
+	f, err := os.Open(*fromFile)
+	if err != nil {
+		reportError(err)
+		close(batches)
+		return
+	}
+	defer f.Close()
+	readCandidates(f, batches)
+}
+
+const (
+	// stdinBatch and stdinFlush bound how long a line waits before it is
+	// listed: a batch goes out once it is full or input pauses.
+	stdinBatch = 4096
+	stdinFlush = 50 * time.Millisecond
+)
+
+// readCandidates sends the non-empty lines of r on batches, in batches, as
+// they arrive, closing batches at the end of input. A read error ends the
+// input early and is reported like a walk error.
+func readCandidates(r io.Reader, batches chan<- []string) {
+	defer close(batches)
+
+	lines := make(chan string, stdinBatch)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				lines <- line
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			reportError(err)
+		}
+	}()
+
+	tick := time.NewTicker(stdinFlush)
+	defer tick.Stop()
+	var batch []string
+	for {
+		select {
+		case <-quit:
+			return
+		case line, ok := <-lines:
+			if !ok {
+				if len(batch) > 0 {
+					batches <- batch
+				}
+				return
+			}
+			batch = append(batch, line)
+			if len(batch) < stdinBatch {
+				continue
+			}
+		case <-tick.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		batches <- batch
+		batch = nil
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadCandidates(t *testing.T) {
+	r, w := io.Pipe()
+	batches := make(chan []string)
+	go readCandidates(r, batches)
+
+	// a line is listed once input pauses, without waiting for the end
+	io.WriteString(w, "src/main.go\n")
+	select {
+	case batch := <-batches:
+		if want := []string{"src/main.go"}; !reflect.DeepEqual(batch, want) {
+			t.Errorf("got %v, want %v", batch, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the first line wasn't listed while input was still open")
+	}
+
+	// blank lines are skipped, and the rest arrive at the end of input
+	go func() {
+		io.WriteString(w, "\nREADME.md\ndocs\n")
+		w.Close()
+	}()
+	var got []string
+	for batch := range batches {
+		got = append(got, batch...)
+	}
+	if want := []string{"README.md", "docs"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestInitFrom(t *testing.T) {
+	defer func(from string, stdin bool) { *fromFile, *fromStdin = from, stdin }(*fromFile, *fromStdin)
+	list := writeConfig(t, "dirs.txt", "")
+	defer os.RemoveAll(filepath.Dir(list))
+
+	*fromFile = list
+	if err := initFrom(); err != nil {
+		t.Errorf("-from %s: %v", list, err)
+	}
+	*fromStdin = true
+	if err := initFrom(); err == nil {
+		t.Error("-stdin and -from accepted together")
+	}
+	*fromStdin, *fromFile = false, filepath.Join(filepath.Dir(list), "missing.txt")
+	if err := initFrom(); err == nil {
+		t.Error("a missing -from file accepted")
+	}
+}
+
+func TestReadListFrom(t *testing.T) {
+	defer func(from string) { *fromFile = from }(*fromFile)
+	list := writeConfig(t, "dirs.txt", "/base/src\n\n/base/docs\n")
+	defer os.RemoveAll(filepath.Dir(list))
+	*fromFile = list
+
+	// unlike stdin, the file can be read again, as a refresh does
+	for i := 0; i < 2; i++ {
+		batches := make(chan []string)
+		go readList(batches)
+		var got []string
+		for batch := range batches {
+			got = append(got, batch...)
+		}
+		if want := []string{"/base/src", "/base/docs"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("read %d: got %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+func TestRelativePathFrom(t *testing.T) {
+	defer func(from string) { *fromFile = from }(*fromFile)
+	*fromFile = "dirs.txt"
+	b := &searchBox{basepath: filepath.FromSlash("/base")}
+
+	// lines are only shortened when they lie beneath the basepath
+	for path, want := range map[string]string{
+		"/base/src/api": "src/api",
+		"/base":         ".",
+		"/basement":     "/basement",
+		"/other/src":    "/other/src",
+		"src/api":       "src/api",
+	} {
+		if got := b.relativePath(filepath.FromSlash(path)); got != filepath.FromSlash(want) {
+			t.Errorf("%s: got %s, want %s", path, got, want)
+		}
+	}
+}
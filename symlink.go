@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"sync"
+)
+
+var listSymlinks = flag.Bool("symlinks", false, "list symlinks to directories, without descending into them, matching on the link or its target")
+
+// targetMap records where each listed symlink points.
+type targetMap struct {
+	mu      sync.Mutex
+	targets map[string]string
+}
+
+var linkTargets = &targetMap{targets: map[string]string{}}
+
+func (m *targetMap) Set(link, target string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.targets[link] = target
+}
+
+func (m *targetMap) Get(link string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target, ok := m.targets[link]
+	return target, ok
+}
+
+// readLink returns the target of the symlink at path, as written in the link,
+// if it leads to a directory.
+func readLink(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return "", false
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", false
+	}
+	return target, true
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/mattn/go-runewidth"
+	"github.com/nsf/termbox-go"
+	"golang.org/x/text/unicode/norm"
+)
+
+// cellWidth is how many columns r takes: 2 for wide East Asian runes and most
+// emoji, and 0 for combining marks, joiners and the like, which termbox can't
+// stack onto a cell and so are never drawn.
+func cellWidth(r rune) int {
+	return runewidth.RuneWidth(r)
+}
+
+// textWidth is how many columns s takes.
+func textWidth(s []rune) int {
+	var width int
+	for _, r := range s {
+		width += cellWidth(r)
+	}
+	return width
+}
+
+// dropColumns removes the runes that fill the first n columns of s. A wide rune
+// straddling column n is dropped too.
+func dropColumns(s []rune, n int) []rune {
+	for len(s) > 0 && n > 0 {
+		n -= cellWidth(s[0])
+		s = s[1:]
+	}
+	// skip marks left over from a rune dropped above
+	for len(s) > 0 && cellWidth(s[0]) == 0 {
+		s = s[1:]
+	}
+	return s
+}
+
+// drawText draws s from column x on row y, stopping before column end, and
+// returns the column after the last cell drawn. Combining sequences are
+// composed first where possible, so accented text keeps its accents.
+func drawText(x, y, end int, s string, fg, bg termbox.Attribute) int {
+	for _, r := range norm.NFC.String(s) {
+		w := cellWidth(r)
+		if w == 0 {
+			continue
+		}
+		if x+w > end {
+			break
+		}
+		setCell(x, y, r, fg, bg)
+		x += w
+	}
+	return x
+}
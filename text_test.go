@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+func TestTextWidth(t *testing.T) {
+	for s, want := range map[string]int{
+		"src":        3,
+		"日本":         4,
+		"cafe\u0301": 4,
+		"a\u200db":   2,
+	} {
+		if got := textWidth([]rune(s)); got != want {
+			t.Errorf("%q is %d columns wide, want %d", s, got, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"abc", 1, "bc"},
+		{"日本語", 2, "本語"},
+		// a wide rune straddling the cut goes too
+		{"日本語", 3, "語"},
+		// and so do the marks of a dropped rune
+		{"e\u0301x", 1, "x"},
+	} {
+		if got := string(dropColumns([]rune(tt.s), tt.n)); got != tt.want {
+			t.Errorf("dropping %d columns of %q left %q, want %q", tt.n, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	clearScreen()
+	// the decomposed é is drawn composed, and the wide rune that won't fit
+	// before column 7 isn't drawn at all
+	end := drawText(0, 0, 7, "cafe\u0301 日本", termbox.ColorDefault, termbox.ColorDefault)
+	if got, want := screenRow(0), "caf\u00e9 日"; got != want {
+		t.Errorf("drew %q, want %q", got, want)
+	}
+	if end != 7 {
+		t.Errorf("ended at column %d, want 7", end)
+	}
+}
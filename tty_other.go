@@ -0,0 +1,16 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+// inlineSupported is false here, where the terminal can only be drawn on by
+// taking over the whole screen.
+const inlineSupported = false
+
+func cursorRow() (int, error) {
+	return 0, errNoInline
+}
+
+func ttySize(fd uintptr) (w, h int, err error) {
+	return 0, 0, errNoInline
+}
@@ -0,0 +1,80 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// inlineSupported reports whether a view can be drawn below the cursor.
+const inlineSupported = true
+
+// cursorRow asks the terminal which row the cursor is on, counting from 0.
+// It has to run before termbox starts reading the terminal, which would
+// swallow the reply.
+func cursorRow() (int, error) {
+	fd, err := syscall.Open("/dev/tty", syscall.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer syscall.Close(fd)
+
+	var orig syscall.Termios
+	if err := ioctl(fd, getTermios, unsafe.Pointer(&orig)); err != nil {
+		return 0, err
+	}
+	// unbuffered and unechoed, giving up after half a second of silence
+	raw := orig
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO
+	raw.Cc[syscall.VMIN] = 0
+	raw.Cc[syscall.VTIME] = 5
+	if err := ioctl(fd, setTermios, unsafe.Pointer(&raw)); err != nil {
+		return 0, err
+	}
+	defer ioctl(fd, setTermios, unsafe.Pointer(&orig))
+
+	if _, err := syscall.Write(fd, []byte("\x1b[6n")); err != nil {
+		return 0, err
+	}
+	var reply []byte
+	buf := make([]byte, 32)
+	for len(reply) < 64 {
+		n, err := syscall.Read(fd, buf)
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			break
+		}
+		reply = append(reply, buf[:n]...)
+		// anything typed ahead comes first, so look for the last reply
+		if i := bytes.LastIndex(reply, []byte("\x1b[")); i >= 0 && reply[len(reply)-1] == 'R' {
+			var row, col int
+			if _, err := fmt.Sscanf(string(reply[i:]), "\x1b[%d;%dR", &row, &col); err == nil {
+				return row - 1, nil
+			}
+		}
+	}
+	return 0, errors.New("the terminal didn't report the cursor position")
+}
+
+// ttySize is the size of the terminal fd is open on.
+func ttySize(fd uintptr) (w, h int, err error) {
+	var size struct{ rows, cols, xpixels, ypixels uint16 }
+	if err := ioctl(int(fd), syscall.TIOCGWINSZ, unsafe.Pointer(&size)); err != nil {
+		return 0, 0, err
+	}
+	return int(size.cols), int(size.rows), nil
+}
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var (
+	watchTree  = flag.Bool("watch", false, "keep the index up to date as directories are created and removed")
+	maxWatches = flag.Int("max-watches", 8192, "stop watching once this many directories are watched")
+)
+
+// watcher is nil unless -watch is set.
+var watcher *treeWatcher
+
+// treeWatcher watches every indexed directory, adding and removing results as
+// directories come and go. It disables itself rather than failing when it runs
+// out of watches.
+type treeWatcher struct {
+	fsw *fsnotify.Watcher
+
+	mu sync.Mutex
+	// watched holds the paths given to fsw, which count towards -max-watches
+	watched  map[string]bool
+	disabled bool
+}
+
+func newTreeWatcher() (*treeWatcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &treeWatcher{fsw: fsw, watched: map[string]bool{}}
+	go w.run()
+	return w, nil
+}
+
+// Add watches each of paths, skipping -files files, whose directory is
+// already watched.
+func (w *treeWatcher) Add(paths []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, path := range paths {
+		if w.disabled {
+			return
+		}
+		if indexedFiles.Has(path) {
+			continue
+		}
+		if len(w.watched) >= *maxWatches {
+			w.disable("reached -max-watches")
+			return
+		}
+		if err := w.fsw.Add(path); err != nil {
+			w.disable(err.Error())
+			return
+		}
+		w.watched[path] = true
+	}
+}
+
+// forget drops path and everything watched beneath it, whose watches the
+// kernel removes along with the directories. w.mu must be held.
+func (w *treeWatcher) forget(path string) {
+	prefix := path + string(filepath.Separator)
+	for p := range w.watched {
+		if p == path || strings.HasPrefix(p, prefix) {
+			delete(w.watched, p)
+		}
+	}
+}
+
+// disable stops all watching, leaving the index as it is. w.mu must be held.
+func (w *treeWatcher) disable(reason string) {
+	log.Printf("watch disabled: %s", reason)
+	w.disabled = true
+	w.fsw.Close()
+}
+
+func (w *treeWatcher) run() {
+	for {
+		select {
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.handle(ev)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("watch: %v", err)
+		}
+	}
+}
+
+func (w *treeWatcher) handle(ev fsnotify.Event) {
+	path := filepath.Clean(ev.Name)
+	listings.Invalidate(filepath.Dir(path))
+	listings.Invalidate(path)
+	switch {
+	case ev.Op&fsnotify.Create != 0:
+		info, err := os.Stat(path)
+		if err != nil {
+			return
+		}
+		skip := ignored
+		if info.Mode().IsRegular() {
+			skip = ignoredFile
+		}
+		if skip(path) {
+			return
+		}
+		if keepMtimes() {
+			mtimes.Set(path, info.ModTime())
+		}
+		if *includeFiles && info.Mode().IsRegular() {
+			indexedFiles.Add(path)
+			results.AppendFilepaths([]string{path})
+			draw()
+			return
+		}
+		if !info.IsDir() {
+			return
+		}
+		results.AppendFilepaths([]string{path})
+		w.Add([]string{path})
+		// anything created inside before the watch was added
+		dirs := make(chan []string)
+		go walk(path, dirs)
+		for filepaths := range dirs {
+			results.AppendFilepaths(filepaths)
+			w.Add(filepaths)
+		}
+		draw()
+	case ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		// the kernel drops watches on removed directories itself
+		results.RemoveFilepaths(path)
+		w.mu.Lock()
+		w.forget(path)
+		w.mu.Unlock()
+		draw()
+	}
+}
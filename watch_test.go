@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestWatchRemoveFiles removes a directory holding a -files file, which was
+// indexed but never watched, and checks only the directory's watch is let go.
+func TestWatchRemoveFiles(t *testing.T) {
+	defer func(files bool) { *includeFiles = files }(*includeFiles)
+	*includeFiles = true
+	dir, err := ioutil.TempDir("", "nav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sub, file := filepath.Join(dir, "src"), filepath.Join(dir, "src", "main.go")
+	os.Mkdir(sub, 0755)
+	ioutil.WriteFile(file, nil, 0644)
+	indexedFiles.Add(file)
+
+	w, err := newTreeWatcher()
+	if err != nil {
+		t.Skip("no watches:", err)
+	}
+	defer w.fsw.Close()
+	w.Add([]string{dir, sub, file})
+	if len(w.watched) != 2 {
+		t.Fatalf("watching %v, want the two directories", w.watched)
+	}
+
+	defer settle(results, runtime.NumGoroutine())
+	results.mu.Lock()
+	results.setIndex([]string{dir, sub, file})
+	results.mu.Unlock()
+	defer func() {
+		results.mu.Lock()
+		results.setIndex(nil)
+		results.mu.Unlock()
+	}()
+	w.handle(fsnotify.Event{Name: sub, Op: fsnotify.Remove})
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.watched) != 1 || !w.watched[dir] {
+		t.Errorf("watching %v after removing src, want only %s", w.watched, dir)
+	}
+}